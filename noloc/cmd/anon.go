@@ -2,41 +2,59 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
-	"github.com/mmcloughlin/geohash"
 	"github.com/nbd-wtf/go-nostr"
-	"github.com/nbd-wtf/go-nostr/nip19"
-	"github.com/nbd-wtf/go-nostr/nip44"
 	"github.com/spf13/cobra"
+
+	"noloc/location"
 )
 
 var anonCmd = &cobra.Command{
 	Use:   "anon",
 	Short: "Listen for location messages from all known identities",
 	Long: `Subscribe to a Nostr relay and listen for encrypted location events
-from all known npubs. For events without p-tag, attempts to decrypt using
-all known nsecs. Shows one line for each failed attempt and full event
-contents on successful decode.`,
+from all known npubs, including watch-only contacts, labeled by name. For
+events without p-tag, attempts to decrypt using all known nsecs. Shows one line for each failed attempt and full event
+contents on successful decode.
+
+Gift wraps (kind 1059, see 'send --wrap') addressed to known identities
+are unwrapped and the location inside is shown like any other event.
+
+Events signed with keys derived from stored ephemeral seeds (see 'noloc id
+seed') are followed too and labeled with the seed name.
+
+--author restricts the subscription to some senders, e.g. contacts
+imported from a follow list or ephemeral seeds.
+
+--output json or ndjson prints one object per event instead, with the
+decrypting identity and decryption status.`,
 	RunE: runAnon,
 }
 
 func init() {
 	rootCmd.AddCommand(anonCmd)
+	anonCmd.Flags().StringSlice("author", nil, "Only events from these senders (npub, nprofile, hex, user@domain, @identity, @contact or @seed)")
+	addOutputFlag(anonCmd)
 }
 
 func runAnon(cmd *cobra.Command, args []string) error {
 	// Load flags into config
 	LoadFlags(cmd)
 
-	relayURL := k.String("relay")
-	if relayURL == "" {
-		return fmt.Errorf("relay URL is required (--relay)")
+	relayURL, err := primaryRelayURL()
+	if err != nil {
+		return err
+	}
+
+	format, err := parseOutputFormat()
+	if err != nil {
+		return err
 	}
 
 	// Load all known identities
@@ -49,16 +67,48 @@ func runAnon(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no identities found. Use 'noloc id generate' to create identities")
 	}
 
-	// Collect all npubs and nsecs
+	// Collect all npubs and signers
 	var npubs []string
-	nsecs := make(map[string]string) // map[name]nsec
+	signers := make(map[string]Signer) // map[name]signer
 	for name, id := range identities {
+		if id.Hex == "" {
+			continue // Seeds have no key pair
+		}
 		npubs = append(npubs, id.Hex) // Use hex pubkey for filter
-		nsecs[name] = id.Nsec
+		signer, err := anonSigner(name, id)
+		if err != nil {
+			log.Printf("Skipping identity %s: %v", name, err)
+			continue
+		}
+		if signer != nil {
+			signers[name] = signer
+		}
+	}
+
+	// Follow the derived keys of ephemeral seeds
+	seeds := storedSeeds()
+
+	// Restrict to the given senders
+	restricted := false
+	if authors := stringList("author"); len(authors) > 0 {
+		restricted = true
+		npubs = nil
+		seeds = nil
+		for _, author := range authors {
+			if seed, err := resolveSeed(author); err == nil {
+				seeds = append(seeds, seed)
+				continue
+			}
+			pubkey, err := resolvePubkey(author)
+			if err != nil {
+				return fmt.Errorf("failed to resolve author: %w", err)
+			}
+			npubs = append(npubs, pubkey)
+		}
 	}
 
 	log.Printf("Starting anonymous location listener...")
-	log.Printf("Monitoring %d known identities", len(identities))
+	log.Printf("Monitoring %d authors and %d ephemeral seeds", len(npubs), len(seeds))
 	log.Printf("Relay: %s", relayURL)
 	log.Println("Listening for encrypted location messages...")
 
@@ -73,53 +123,92 @@ func runAnon(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
-	relay, err := nostr.RelayConnect(ctx, relayURL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to relay: %w", err)
-	}
-	defer relay.Close()
-
 	// Create filter for location events from known pubkeys
-	filters := []nostr.Filter{{
+	filters := nostr.Filters{{
 		Kinds:   []int{30473},
 		Authors: npubs,
 	}}
 
-	sub, err := relay.Subscribe(ctx, filters)
-	if err != nil {
-		return fmt.Errorf("failed to subscribe: %w", err)
+	// Gift wraps hide their sender, they are matched by receiver instead
+	var receivers []string
+	for _, signer := range signers {
+		receivers = append(receivers, signer.PublicKey())
+	}
+	if len(receivers) > 0 {
+		filters = append(filters, nostr.Filter{
+			Kinds: []int{nostr.KindGiftWrap},
+			Tags:  nostr.TagMap{"p": receivers},
+		})
 	}
 
-	log.Println("Subscribed to location events. Press Ctrl+C to exit.")
-	fmt.Println("=============================================================")
+	if format == outputText {
+		fmt.Println("=============================================================")
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case event := <-sub.Events:
-			if event == nil {
-				continue
+	authors := newAuthorIndex()
+	return subscribeEphemeral(ctx, relayURL, filters, seeds, func(event *nostr.Event) {
+		var wrapID string
+		if event.Kind == nostr.KindGiftWrap {
+			wrapID = event.ID
+			if event = unwrapAnonEvent(event, signers); event == nil {
+				return
+			}
+			if restricted && !containsString(npubs, event.PubKey) && !ownedBySeed(seeds, event) {
+				return
 			}
+		}
 
-			processAnonEvent(event, identities, nsecs)
+		if format != outputText {
+			out := anonEventOutput(event, authors, signers)
+			out.WrapID = wrapID
+			writeEventOutput(format, out)
+			return
 		}
+		processAnonEvent(event, wrapID, authors, signers)
+	})
+}
+
+// unwrapAnonEvent opens a gift wrap with the identity named in its p-tag
+// and returns the location event inside, nil when the wrap is not for us
+// or holds something else
+func unwrapAnonEvent(wrap *nostr.Event, signers map[string]Signer) *nostr.Event {
+	pTag := wrap.Tags.Find("p")
+	if pTag == nil {
+		return nil
 	}
+	for name, signer := range signers {
+		if signer.PublicKey() != pTag[1] {
+			continue
+		}
+		event, err := location.UnwrapEvent(context.Background(), signer, wrap)
+		if err != nil {
+			if !errors.Is(err, location.ErrNotLocation) {
+				log.Printf("Skipping gift wrap %s for %s: %v", wrap.ID, name, err)
+			}
+			return nil
+		}
+		return event
+	}
+	return nil
 }
 
-func processAnonEvent(event *nostr.Event, identities map[string]Identity, nsecs map[string]string) {
+// ownedBySeed reports whether the event was signed with a key of any seed
+func ownedBySeed(seeds []*ephemeralSeed, event *nostr.Event) bool {
+	for _, seed := range seeds {
+		if seed.owns(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func processAnonEvent(event *nostr.Event, wrapID string, authors *authorIndex, signers map[string]Signer) {
 	fmt.Printf("\n📍 Location Event Received\n")
 	fmt.Printf("Event ID: %s\n", event.ID)
-	fmt.Printf("From: %s", event.PubKey)
-	
-	// Find sender name if known
-	for name, id := range identities {
-		if id.Hex == event.PubKey {
-			fmt.Printf(" (%s)", name)
-			break
-		}
+	if wrapID != "" {
+		fmt.Printf("Gift wrap: %s (NIP-59)\n", wrapID)
 	}
-	fmt.Println()
+	fmt.Printf("From: %s\n", authors.label(event))
 	
 	fmt.Printf("Created: %s\n", event.CreatedAt.Time().Format("2006-01-02 15:04:05"))
 
@@ -141,19 +230,19 @@ func processAnonEvent(event *nostr.Event, identities map[string]Identity, nsecs
 		fmt.Printf("Has p-tag: %s\n", pTagValue)
 		
 		// Find matching identity
-		var matchingNsec string
+		var matchingSigner Signer
 		var matchingName string
-		for name, id := range identities {
-			if id.Hex == pTagValue {
-				matchingNsec = id.Nsec
+		for name, signer := range signers {
+			if signer.PublicKey() == pTagValue {
+				matchingSigner = signer
 				matchingName = name
 				break
 			}
 		}
 
-		if matchingNsec != "" {
+		if matchingSigner != nil {
 			fmt.Printf("Attempting decrypt with identity: %s\n", matchingName)
-			if tryDecryptLocation(event, matchingNsec, matchingName) {
+			if tryDecryptLocation(event, matchingSigner, matchingName) {
 				// Success, already printed
 			} else {
 				fmt.Printf("  ❌ Failed to decrypt with %s\n", matchingName)
@@ -162,12 +251,12 @@ func processAnonEvent(event *nostr.Event, identities map[string]Identity, nsecs
 			fmt.Printf("⚠️  No matching identity for p-tag\n")
 		}
 	} else {
-		// No p-tag, try all known nsecs
+		// No p-tag, try all known local keys
 		fmt.Println("No p-tag (anonymous). Trying all known identities...")
 		
 		successCount := 0
-		for name, nsec := range nsecs {
-			if tryDecryptLocation(event, nsec, name) {
+		for name, signer := range trialSigners(signers) {
+			if tryDecryptLocation(event, signer, name) {
 				successCount++
 				break // Stop after first successful decrypt
 			} else {
@@ -183,28 +272,84 @@ func processAnonEvent(event *nostr.Event, identities map[string]Identity, nsecs
 	fmt.Println("=============================================================")
 }
 
-func tryDecryptLocation(event *nostr.Event, nsec string, identityName string) bool {
-	// Decode nsec to get secret key
-	_, skRaw, err := nip19.Decode(nsec)
-	if err != nil {
-		return false
+// anonEventOutput decrypts the event like processAnonEvent, without
+// printing the individual attempts
+func anonEventOutput(event *nostr.Event, authors *authorIndex, signers map[string]Signer) *eventOutput {
+	authorName := authors.name(event)
+
+	// With a p-tag only the addressed identity can decrypt
+	candidates := trialSigners(signers)
+	pTag := event.Tags.Find("p")
+	if pTag != nil {
+		candidates = make(map[string]Signer)
+		for name, signer := range signers {
+			if signer.PublicKey() == pTag[1] {
+				candidates[name] = signer
+				break
+			}
+		}
 	}
-	sk := skRaw.(string)
 
-	// Try to decrypt
-	conversationKey, err := nip44.GenerateConversationKey(event.PubKey, sk)
-	if err != nil {
-		return false
+	var out *eventOutput
+	var lastErr error
+	for name, signer := range candidates {
+		locationData, err := decryptLocation(event, signer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		out = newEventOutput(event, locationData, statusDecrypted, nil)
+		out.DecryptedBy = name
+		break
 	}
 
-	decryptedContent, err := nip44.Decrypt(event.Content, conversationKey)
-	if err != nil {
-		return false
+	// Anonymous events failing with every key are simply not for us
+	if out == nil {
+		if pTag != nil && lastErr != nil {
+			out = newEventOutput(event, nil, statusFailed, lastErr)
+		} else {
+			out = newEventOutput(event, nil, statusNoKey, nil)
+		}
 	}
+	out.AuthorName = authorName
+	return out
+}
 
-	// Try to parse as location data
-	var locationData [][]interface{}
-	if err := json.Unmarshal([]byte(decryptedContent), &locationData); err != nil {
+// anonSigner returns the signer of a loaded identity, nil for identities
+// without a private key
+func anonSigner(name string, id Identity) (Signer, error) {
+	switch {
+	case id.Bunker != "":
+		return identityBunkerSigner(name, id)
+	case id.Nsec != "":
+		return nsecSigner(id.Nsec)
+	default:
+		return nil, nil
+	}
+}
+
+// trialSigners returns the signers tried on anonymous events. Remote
+// signers would be asked to decrypt every event, so only local keys are
+// tried.
+func trialSigners(signers map[string]Signer) map[string]Signer {
+	trial := make(map[string]Signer)
+	for name, signer := range signers {
+		if _, remote := signer.(*bunkerSigner); !remote {
+			trial[name] = signer
+		}
+	}
+	return trial
+}
+
+// decryptLocation decrypts the location tags of the event with a signer
+func decryptLocation(event *nostr.Event, signer Signer) (nostr.Tags, error) {
+	return location.DecryptContentWith(context.Background(), signer, event.Content, event.PubKey)
+}
+
+func tryDecryptLocation(event *nostr.Event, signer Signer, identityName string) bool {
+	// Try to decrypt and parse as location data
+	locationData, err := decryptLocation(event, signer)
+	if err != nil {
 		return false
 	}
 
@@ -214,19 +359,15 @@ func tryDecryptLocation(event *nostr.Event, nsec string, identityName string) bo
 	var geohashStr string
 	for _, tag := range locationData {
 		if len(tag) >= 2 {
-			fmt.Printf("  - %v: %v\n", tag[0], tag[1])
+			fmt.Printf("  - %s: %s\n", tag[0], tag[1])
 			if tag[0] == "g" {
-				geohashStr = fmt.Sprintf("%v", tag[1])
+				geohashStr = tag[1]
 			}
 		}
 	}
 
 	if geohashStr != "" {
-		lat, lon := geohash.Decode(geohashStr)
-		fmt.Printf("\n📌 Converted Coordinates:\n")
-		fmt.Printf("  - Latitude:  %.6f\n", lat)
-		fmt.Printf("  - Longitude: %.6f\n", lon)
-		fmt.Printf("  - Map: https://www.openstreetmap.org/?mlat=%.6f&mlon=%.6f&zoom=4\n", lat, lon)
+		printCoordinates(geohashStr, locationAccuracy(locationData))
 	}
 
 	return true
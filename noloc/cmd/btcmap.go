@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,10 +10,10 @@ import (
 	"strings"
 	"time"
 
-	"github.com/mmcloughlin/geohash"
 	"github.com/nbd-wtf/go-nostr"
-	"github.com/nbd-wtf/go-nostr/nip19"
 	"github.com/spf13/cobra"
+
+	"noloc/location"
 )
 
 type BTCMapPlace struct {
@@ -47,7 +48,7 @@ and broadcasts them as public Nostr location events (kind 30472).`,
 
 func init() {
 	rootCmd.AddCommand(btcmapCmd)
-	btcmapCmd.Flags().StringP("sender", "s", "", "Sender private key (nsec... or @identity)")
+	btcmapCmd.Flags().StringP("sender", "s", "", "Sender private key (nsec..., bunker:// URI or @identity)")
 	btcmapCmd.Flags().Int("limit", 0, "Number of places to fetch (0 = all)")
 	btcmapCmd.Flags().Int("precision", 9, "Geohash precision (1-12 characters)")
 	btcmapCmd.Flags().Int("ttl", 3600, "Event time-to-live in seconds")
@@ -73,8 +74,11 @@ func runBTCMap(cmd *cobra.Command, args []string) error {
 	} else {
 		log.Printf("Limit: all places")
 	}
-	log.Printf("Relay: %s", config.relayURL)
+	log.Printf("Relays: %s (quorum %d)", strings.Join(config.relayURLs, ", "), publishQuorum(len(config.relayURLs)))
 	log.Printf("Mode: Public broadcast (kind 30472)")
+	if config.policy.Active() {
+		log.Printf("Privacy: %s", config.policy)
+	}
 
 	places, err := fetchBTCMapPlaces(config)
 	if err != nil {
@@ -82,6 +86,7 @@ func runBTCMap(cmd *cobra.Command, args []string) error {
 	}
 
 	log.Printf("Fetched %d places from BTCMap", len(places))
+	defer pool.close()
 
 	for i, place := range places {
 		if err := processBTCMapPlace(config, place); err != nil {
@@ -98,12 +103,13 @@ func runBTCMap(cmd *cobra.Command, args []string) error {
 }
 
 type btcmapConfig struct {
-	senderSK  string
-	relayURL  string
+	signer    Signer
+	relayURLs []string
 	limit     int
 	precision int
 	ttl       int
 	filter    string
+	policy    location.Policy
 }
 
 func validateBTCMapConfig() (*btcmapConfig, error) {
@@ -112,18 +118,14 @@ func validateBTCMapConfig() (*btcmapConfig, error) {
 		return nil, fmt.Errorf("sender is required (--sender or -s)")
 	}
 
-	relayURL := k.String("relay")
-	if relayURL == "" {
-		return nil, fmt.Errorf("relay URL is required (--relay)")
-	}
-
-	if !strings.HasPrefix(sender, "nsec1") {
-		return nil, fmt.Errorf("sender must be an nsec private key (starting with 'nsec1') or @identity reference")
+	relayURLs, err := relayURLs()
+	if err != nil {
+		return nil, err
 	}
 
-	_, senderSK, err := nip19.Decode(sender)
+	signer, err := resolveSigner(sender)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode sender nsec: %w", err)
+		return nil, fmt.Errorf("failed to resolve sender: %w", err)
 	}
 
 	limit := k.Int("limit")
@@ -148,12 +150,13 @@ func validateBTCMapConfig() (*btcmapConfig, error) {
 	}
 
 	return &btcmapConfig{
-		senderSK:  senderSK.(string),
-		relayURL:  relayURL,
+		signer:    signer,
+		relayURLs: relayURLs,
 		limit:     limit,
 		precision: precision,
 		ttl:       ttl,
 		filter:    filter,
+		policy:    privacyPolicy(identityName(signer.PublicKey())),
 	}, nil
 }
 
@@ -207,7 +210,9 @@ func processBTCMapPlace(config *btcmapConfig, place BTCMapPlace) error {
 		return fmt.Errorf("failed to create event: %w", err)
 	}
 
-	if err := publishToRelay(config.relayURL, event); err != nil {
+	results, err := publishToRelays(config.relayURLs, event)
+	logPublishFailures(results)
+	if err != nil {
 		return fmt.Errorf("failed to publish: %w", err)
 	}
 
@@ -215,71 +220,33 @@ func processBTCMapPlace(config *btcmapConfig, place BTCMapPlace) error {
 }
 
 func createBTCMapLocationEvent(config *btcmapConfig, place BTCMapPlace) (*nostr.Event, error) {
-	gh := geohash.EncodeWithPrecision(place.Lat, place.Lon, uint(config.precision))
-
-	expiration := time.Now().Add(time.Duration(config.ttl) * time.Second).Unix()
-
-	// Build tags for public location event (kind 30472)
-	tags := nostr.Tags{
-		{"g", gh},
-		{"d", fmt.Sprintf("btcmap-%d", place.ID)},
-		{"expiration", fmt.Sprintf("%d", expiration)},
-		{"title", place.Name},
+	loc := &location.Location{
+		Title:    place.Name,
+		Hashtags: []string{"btcmap", "bitcoin", "merchant"}, // BTCMap specific tags
 	}
+	loc.Geohash, loc.Accuracy = config.policy.Obfuscate(place.Lat, place.Lon, 0, config.precision)
 
 	// Add optional tags
-	if place.Icon != "" {
-		tags = append(tags, nostr.Tag{"icon", place.Icon})
-	}
-	if place.Address != "" {
-		tags = append(tags, nostr.Tag{"location", place.Address})
-	}
-	if place.Phone != "" {
-		tags = append(tags, nostr.Tag{"phone", place.Phone})
-	}
-	if place.Website != "" {
-		tags = append(tags, nostr.Tag{"website", place.Website})
-	}
-	if place.Email != "" {
-		tags = append(tags, nostr.Tag{"email", place.Email})
-	}
-	if place.Twitter != "" {
-		tags = append(tags, nostr.Tag{"twitter", place.Twitter})
-	}
-	if place.OpeningHours != "" {
-		tags = append(tags, nostr.Tag{"opening_hours", place.OpeningHours})
-	}
-	if place.VerifiedAt != "" {
-		tags = append(tags, nostr.Tag{"verified_at", place.VerifiedAt})
-	}
-	if place.CreatedAt != "" {
-		tags = append(tags, nostr.Tag{"created_at", place.CreatedAt})
-	}
-	if place.UpdatedAt != "" {
-		tags = append(tags, nostr.Tag{"updated_at", place.UpdatedAt})
-	}
-
-	// Add BTCMap specific tags
-	tags = append(tags, nostr.Tag{"t", "btcmap"})
-	tags = append(tags, nostr.Tag{"t", "bitcoin"})
-	tags = append(tags, nostr.Tag{"t", "merchant"})
-
-	senderPubkey, err := nostr.GetPublicKey(config.senderSK)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get sender public key: %w", err)
-	}
-
-	event := &nostr.Event{
-		PubKey:    senderPubkey,
-		CreatedAt: nostr.Timestamp(time.Now().Unix()),
-		Kind:      30472,
-		Tags:      tags,
-		Content:   "", // No content for public location events
-	}
-
-	if err := event.Sign(config.senderSK); err != nil {
-		return nil, fmt.Errorf("failed to sign event: %w", err)
+	optional := []struct{ key, value string }{
+		{"icon", place.Icon},
+		{"location", place.Address},
+		{"phone", place.Phone},
+		{"website", place.Website},
+		{"email", place.Email},
+		{"twitter", place.Twitter},
+		{"opening_hours", place.OpeningHours},
+		{"verified_at", place.VerifiedAt},
+		{"created_at", place.CreatedAt},
+		{"updated_at", place.UpdatedAt},
+	}
+	for _, tag := range optional {
+		if tag.value != "" {
+			loc.Extra = append(loc.Extra, nostr.Tag{tag.key, tag.value})
+		}
 	}
 
-	return event, nil
+	return location.SignPublicEvent(context.Background(), config.signer, loc, location.EventOptions{
+		D:   fmt.Sprintf("btcmap-%d", place.ID),
+		TTL: time.Duration(config.ttl) * time.Second,
+	})
 }
@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/cobra"
+)
+
+// metadataBatch is the number of authors per kind 0 query
+const metadataBatch = 250
+
+// invalidNameChars matches characters replaced in names taken from profiles
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+var contactCmd = &cobra.Command{
+	Use:   "contact",
+	Short: "Manage watch-only contacts",
+	Long: `Contacts are other people's public keys stored under a petname next to
+your identities. A contact has no private key, so @petname works wherever a
+public key is expected (send --receiver, listen --author), and listen and anon
+label events from contacts with their petname.`,
+}
+
+var contactAddCmd = &cobra.Command{
+	Use:   "add <petname> <npub|hex|@name>",
+	Short: "Add or update a watch-only contact",
+	Args:  cobra.ExactArgs(2),
+	RunE:  addContact,
+}
+
+var contactImportCmd = &cobra.Command{
+	Use:   "import [npub|hex|@name]",
+	Short: "Import contacts from a NIP-02 follow list",
+	Long: `Import the kind 3 follow list of a user as watch-only contacts, from the
+relay or with --file from a JSON file of events (a JSON array or
+newline-delimited JSON, as written by most Nostr tools).
+
+Contacts are named by the petname in the follow list, or else by the name
+in their kind 0 metadata, fetched from the relay or read from the same file.
+The relay of each follow list entry becomes the contact's preferred relay.
+Existing contacts with the same key are updated, other names are never
+replaced.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: importContacts,
+}
+
+func init() {
+	idCmd.AddCommand(contactCmd)
+	contactCmd.AddCommand(contactAddCmd)
+	contactCmd.AddCommand(contactImportCmd)
+
+	contactImportCmd.Flags().String("file", "", "Read the follow list and metadata events from this JSON file instead of the relay")
+	contactImportCmd.Flags().Bool("dry-run", false, "Show the contacts that would be imported without saving them")
+
+	contactAddCmd.Flags().String("note", "", "Free-form note about the contact")
+	contactAddCmd.Flags().StringSlice("preferred-relay", nil, "Preferred relays of the contact (repeat or comma-separate)")
+}
+
+func addContact(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	pubkey, err := resolvePubkey(args[1])
+	if err != nil {
+		return err
+	}
+	npub, err := nip19.EncodePublicKey(pubkey)
+	if err != nil {
+		return fmt.Errorf("failed to encode npub: %w", err)
+	}
+
+	note, _ := cmd.Flags().GetString("note")
+	relays, _ := cmd.Flags().GetStringSlice("preferred-relay")
+
+	identities, err := readIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+
+	// Contacts are updated in place, identities with keys are never replaced
+	contact := Identity{
+		Name:    name,
+		Npub:    npub,
+		Hex:     pubkey,
+		Added:   time.Now().Format("2006-01-02 15:04:05"),
+		Contact: true,
+	}
+	existing, exists := identities[name]
+	if exists {
+		if !existing.Contact {
+			return fmt.Errorf("identity '%s' already exists and is not a contact", name)
+		}
+		contact = existing
+		contact.Npub = npub
+		contact.Hex = pubkey
+	}
+	if cmd.Flags().Changed("note") {
+		contact.Note = note
+	}
+	if cmd.Flags().Changed("preferred-relay") {
+		contact.Relays = relays
+	}
+	identities[name] = contact
+
+	if err := saveIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save identities: %w", err)
+	}
+
+	if exists {
+		fmt.Printf("Updated contact '%s'\n", name)
+	} else {
+		fmt.Printf("Added contact '%s'\n", name)
+	}
+	fmt.Printf("  Npub: %s\n", npub)
+
+	return nil
+}
+
+// followedContact is one entry of a NIP-02 follow list
+type followedContact struct {
+	pubkey  string
+	relay   string
+	petname string
+}
+
+func importContacts(cmd *cobra.Command, args []string) error {
+	LoadFlags(cmd)
+
+	var follows, profiles []*nostr.Event
+	var err error
+	if file := k.String("file"); file != "" {
+		follows, err = readEventsInput([]string{file})
+		if err != nil {
+			return err
+		}
+		profiles = follows
+		if len(args) == 1 {
+			pubkey, err := resolvePubkey(args[0])
+			if err != nil {
+				return err
+			}
+			follows = eventsByAuthor(follows, pubkey)
+		}
+	} else {
+		if len(args) == 0 {
+			return fmt.Errorf("give the user whose follow list to import, or --file")
+		}
+		pubkey, err := resolvePubkey(args[0])
+		if err != nil {
+			return err
+		}
+		follows, profiles, err = queryFollowList(pubkey)
+		if err != nil {
+			return err
+		}
+	}
+
+	contacts := latestFollowList(follows)
+	if contacts == nil {
+		return fmt.Errorf("no kind 3 follow list found")
+	}
+	names := profileNames(profiles)
+
+	identities, err := readIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+
+	added, updated := 0, 0
+	for _, c := range contacts {
+		name, existing := contactName(identities, c, names)
+		if name == "" {
+			continue
+		}
+
+		contact := identities[name]
+		if !existing {
+			npub, _ := nip19.EncodePublicKey(c.pubkey)
+			contact = Identity{
+				Name:    name,
+				Npub:    npub,
+				Hex:     c.pubkey,
+				Added:   time.Now().Format("2006-01-02 15:04:05"),
+				Contact: true,
+			}
+			added++
+			fmt.Printf("+ %s (%s)\n", name, contact.Npub)
+		} else {
+			updated++
+		}
+		if c.relay != "" && !containsString(contact.Relays, c.relay) {
+			contact.Relays = append(contact.Relays, c.relay)
+		}
+		identities[name] = contact
+	}
+
+	if k.Bool("dry.run") {
+		fmt.Printf("Would import %d new contacts, %d already known (dry run)\n", added, updated)
+		return nil
+	}
+	if err := saveIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save identities: %w", err)
+	}
+
+	fmt.Printf("Imported %d new contacts, %d already known\n", added, updated)
+	return nil
+}
+
+// queryFollowList fetches the kind 3 follow list of a user and the kind 0
+// metadata of everyone on it
+func queryFollowList(pubkey string) ([]*nostr.Event, []*nostr.Event, error) {
+	relayURL, err := primaryRelayURL()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	relay, err := nostr.RelayConnect(ctx, relayURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to relay: %w", err)
+	}
+	defer relay.Close()
+
+	follows, err := relay.QuerySync(ctx, nostr.Filter{
+		Kinds:   []int{nostr.KindFollowList},
+		Authors: []string{pubkey},
+		Limit:   1,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query follow list: %w", err)
+	}
+
+	var pubkeys []string
+	for _, c := range latestFollowList(follows) {
+		pubkeys = append(pubkeys, c.pubkey)
+	}
+
+	var profiles []*nostr.Event
+	for start := 0; start < len(pubkeys); start += metadataBatch {
+		end := min(start+metadataBatch, len(pubkeys))
+		batch, err := relay.QuerySync(ctx, nostr.Filter{
+			Kinds:   []int{nostr.KindProfileMetadata},
+			Authors: pubkeys[start:end],
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to query metadata: %w", err)
+		}
+		profiles = append(profiles, batch...)
+	}
+
+	return follows, profiles, nil
+}
+
+// eventsByAuthor returns the events of one author
+func eventsByAuthor(events []*nostr.Event, pubkey string) []*nostr.Event {
+	var filtered []*nostr.Event
+	for _, event := range events {
+		if event.PubKey == pubkey {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+// latestFollowList returns the entries of the newest kind 3 event, nil when
+// there is none
+func latestFollowList(events []*nostr.Event) []followedContact {
+	var latest *nostr.Event
+	for _, event := range events {
+		if event.Kind == nostr.KindFollowList && (latest == nil || event.CreatedAt > latest.CreatedAt) {
+			latest = event
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+
+	contacts := []followedContact{}
+	seen := make(map[string]bool)
+	for tag := range latest.Tags.FindAll("p") {
+		if !nostr.IsValidPublicKey(tag[1]) || seen[tag[1]] {
+			continue
+		}
+		seen[tag[1]] = true
+		c := followedContact{pubkey: tag[1]}
+		if len(tag) > 2 {
+			c.relay = strings.TrimSpace(tag[2])
+		}
+		if len(tag) > 3 {
+			c.petname = tag[3]
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts
+}
+
+// profileNames reads the names of the newest kind 0 event of each author
+func profileNames(events []*nostr.Event) map[string]string {
+	newest := make(map[string]*nostr.Event)
+	for _, event := range events {
+		if event.Kind != nostr.KindProfileMetadata {
+			continue
+		}
+		if prev, ok := newest[event.PubKey]; !ok || event.CreatedAt > prev.CreatedAt {
+			newest[event.PubKey] = event
+		}
+	}
+
+	names := make(map[string]string)
+	for pubkey, event := range newest {
+		var metadata struct {
+			Name        string `json:"name"`
+			DisplayName string `json:"display_name"`
+		}
+		if err := json.Unmarshal([]byte(event.Content), &metadata); err != nil {
+			continue
+		}
+		if metadata.Name != "" {
+			names[pubkey] = metadata.Name
+		} else if metadata.DisplayName != "" {
+			names[pubkey] = metadata.DisplayName
+		}
+	}
+	return names
+}
+
+// contactName picks the name of an imported contact and reports whether a
+// contact with its key already exists. It returns "" for keys that belong
+// to an identity we hold.
+func contactName(identities map[string]Identity, c followedContact, names map[string]string) (string, bool) {
+	for name, id := range identities {
+		if id.Hex == c.pubkey {
+			if !id.Contact {
+				return "", false
+			}
+			return name, true
+		}
+	}
+
+	base := sanitizeName(c.petname)
+	if base == "" {
+		base = sanitizeName(names[c.pubkey])
+	}
+	if base == "" {
+		npub, _ := nip19.EncodePublicKey(c.pubkey)
+		base = npub[:12]
+	}
+
+	// Names are unique, later contacts with the same name get a suffix
+	name := base
+	for i := 2; ; i++ {
+		if _, taken := identities[name]; !taken {
+			return name, false
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// sanitizeName turns a profile name into a name usable as @name
+func sanitizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = invalidNameChars.ReplaceAllString(name, "-")
+	return strings.Trim(name, "-.")
+}
+
+// authorIndex names the authors of received events: identities and
+// contacts by public key, ephemeral senders by the seed of their key
+type authorIndex struct {
+	names map[string]string
+	seeds []*ephemeralSeed
+}
+
+func newAuthorIndex() *authorIndex {
+	index := &authorIndex{names: make(map[string]string), seeds: storedSeeds()}
+	identities, err := readIdentities()
+	if err != nil {
+		return index
+	}
+	for name, id := range identities {
+		if id.Hex != "" {
+			index.names[id.Hex] = name
+		}
+	}
+	return index
+}
+
+// name returns the name of the event author, "" when unknown
+func (a *authorIndex) name(event *nostr.Event) string {
+	if name, ok := a.names[event.PubKey]; ok {
+		return name
+	}
+	for _, seed := range a.seeds {
+		if seed.owns(event) {
+			return seed.name
+		}
+	}
+	return ""
+}
+
+// label formats the event author with its name when known
+func (a *authorIndex) label(event *nostr.Event) string {
+	if name := a.name(event); name != "" {
+		return fmt.Sprintf("%s (%s)", event.PubKey, name)
+	}
+	return event.PubKey
+}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/cobra"
+)
+
+// contactAddFlags returns a command with the flags of 'contact add', so
+// that flags set by one test do not count as changed in the next
+func contactAddFlags(flags ...string) *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("note", "", "")
+	cmd.Flags().StringSlice("preferred-relay", nil, "")
+	for i := 0; i+1 < len(flags); i += 2 {
+		cmd.Flags().Set(flags[i], flags[i+1])
+	}
+	return cmd
+}
+
+func TestAddContact(t *testing.T) {
+	testConfig(t, "")
+	alice := testIdentity("alice")
+	if err := saveIdentities(map[string]Identity{"alice": alice}); err != nil {
+		t.Fatal(err)
+	}
+	bob, carol := testIdentity("bob"), testIdentity("carol")
+
+	captureOutput(t, func() {
+		if err := addContact(contactAddFlags("note", "Neighbor", "preferred-relay", "wss://bob.example.com"), []string{"bob", bob.Npub}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	identities, _ := readIdentities()
+	contact := identities["bob"]
+	if !contact.Contact || contact.Hex != bob.Hex || contact.Nsec != "" || contact.Note != "Neighbor" {
+		t.Errorf("contact = %+v", contact)
+	}
+
+	// Updating the key keeps the note and relays
+	captureOutput(t, func() {
+		if err := addContact(contactAddFlags(), []string{"bob", carol.Hex}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	identities, _ = readIdentities()
+	if contact := identities["bob"]; contact.Hex != carol.Hex || contact.Note != "Neighbor" || !slices.Equal(contact.Relays, []string{"wss://bob.example.com"}) {
+		t.Errorf("updated contact = %+v", contact)
+	}
+
+	if err := addContact(contactAddFlags(), []string{"alice", bob.Npub}); err == nil || !strings.Contains(err.Error(), "not a contact") {
+		t.Errorf("replacing an identity: %v", err)
+	}
+
+	pubkey, relays, err := resolveProfile("@bob")
+	if err != nil || pubkey != carol.Hex || !slices.Equal(relays, []string{"wss://bob.example.com"}) {
+		t.Errorf("@bob = %s %v, %v", pubkey, relays, err)
+	}
+	if _, err := resolveSigner("@bob"); err == nil || !strings.Contains(err.Error(), "watch-only") {
+		t.Errorf("signing as a contact: %v", err)
+	}
+}
+
+func TestAuthorIndex(t *testing.T) {
+	testConfig(t, "")
+	alice := testIdentity("alice")
+	bob := testIdentity("bob")
+	bob.Nsec = ""
+	bob.Contact = true
+	if err := saveIdentities(map[string]Identity{"alice": alice, "bob": bob}); err != nil {
+		t.Fatal(err)
+	}
+	stranger := testIdentity("stranger").Hex
+
+	index := newAuthorIndex()
+	tests := []struct {
+		pubkey string
+		want   string
+	}{
+		{alice.Hex, alice.Hex + " (alice)"},
+		{bob.Hex, bob.Hex + " (bob)"},
+		{stranger, stranger},
+	}
+	for _, tt := range tests {
+		if got := index.label(&nostr.Event{PubKey: tt.pubkey}); got != tt.want {
+			t.Errorf("label = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"alice", "alice"},
+		{"  Alice Smith ", "alice-smith"},
+		{"Bob ⚡️ Builder", "bob-builder"},
+		{"carol.nostr_dev", "carol.nostr_dev"},
+		{"-.dave.-", "dave"},
+		{"@eve!!", "eve"},
+		{"🤙", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeName(tt.input); got != tt.want {
+			t.Errorf("sanitizeName(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestContactName(t *testing.T) {
+	own := testIdentity("alice")
+	known := testIdentity("bob")
+	known.Contact = true
+	taken := testIdentity("carol")
+	taken.Contact = true
+	identities := map[string]Identity{"alice": own, "bob": known, "carol": taken, "carol-2": {Name: "carol-2", Contact: true}}
+
+	newKey := testIdentity("x").Hex
+	tests := []struct {
+		name     string
+		contact  followedContact
+		profiles map[string]string
+		want     string
+		existing bool
+	}{
+		{"own identity skipped", followedContact{pubkey: own.Hex, petname: "me"}, nil, "", false},
+		{"known contact kept", followedContact{pubkey: known.Hex, petname: "robert"}, nil, "bob", true},
+		{"petname first", followedContact{pubkey: newKey, petname: "Dave"}, map[string]string{newKey: "david"}, "dave", false},
+		{"profile name", followedContact{pubkey: newKey}, map[string]string{newKey: "David K"}, "david-k", false},
+		{"collision suffix", followedContact{pubkey: newKey, petname: "carol"}, nil, "carol-3", false},
+		{"collision with identity", followedContact{pubkey: newKey, petname: "alice"}, nil, "alice-2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, existing := contactName(identities, tt.contact, tt.profiles)
+			if name != tt.want || existing != tt.existing {
+				t.Errorf("got %q, %v, want %q, %v", name, existing, tt.want, tt.existing)
+			}
+		})
+	}
+
+	// Without any name the contact is named by its npub
+	name, _ := contactName(identities, followedContact{pubkey: newKey, petname: "🤙"}, nil)
+	if !strings.HasPrefix(name, "npub1") || len(name) != 12 {
+		t.Errorf("unnamed contact = %q, want an npub prefix", name)
+	}
+}
+
+func TestLatestFollowList(t *testing.T) {
+	bob, carol := testIdentity("bob").Hex, testIdentity("carol").Hex
+	older := &nostr.Event{Kind: nostr.KindFollowList, CreatedAt: 100, Tags: nostr.Tags{{"p", carol}}}
+	newer := &nostr.Event{Kind: nostr.KindFollowList, CreatedAt: 200, Tags: nostr.Tags{
+		{"p", bob, " wss://bob.example.com ", "bobby"},
+		{"p", "not a key"},
+		{"p", bob, "wss://other.example.com"},
+		{"p", carol},
+	}}
+	profile := &nostr.Event{Kind: nostr.KindProfileMetadata, CreatedAt: 300, Tags: nostr.Tags{{"p", bob}}}
+
+	got := latestFollowList([]*nostr.Event{older, profile, newer})
+	want := []followedContact{
+		{pubkey: bob, relay: "wss://bob.example.com", petname: "bobby"},
+		{pubkey: carol},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("follow list = %+v, want %+v", got, want)
+	}
+
+	if got := latestFollowList([]*nostr.Event{profile}); got != nil {
+		t.Errorf("without kind 3 = %+v, want nil", got)
+	}
+	if got := latestFollowList([]*nostr.Event{{Kind: nostr.KindFollowList}}); got == nil || len(got) != 0 {
+		t.Errorf("empty follow list = %#v, want empty", got)
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	bob, carol, dave := testIdentity("bob").Hex, testIdentity("carol").Hex, testIdentity("dave").Hex
+	events := []*nostr.Event{
+		{Kind: nostr.KindProfileMetadata, PubKey: bob, CreatedAt: 200, Content: `{"name":"bob"}`},
+		{Kind: nostr.KindProfileMetadata, PubKey: bob, CreatedAt: 100, Content: `{"name":"old bob"}`},
+		{Kind: nostr.KindProfileMetadata, PubKey: carol, CreatedAt: 100, Content: `{"display_name":"Carol C"}`},
+		{Kind: nostr.KindProfileMetadata, PubKey: dave, CreatedAt: 100, Content: `not json`},
+		{Kind: nostr.KindFollowList, PubKey: dave, CreatedAt: 200, Content: `{"name":"dave"}`},
+	}
+
+	got := profileNames(events)
+	want := map[string]string{bob: "bob", carol: "Carol C"}
+	if len(got) != len(want) || got[bob] != want[bob] || got[carol] != want[carol] {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+}
+
+// writeEventsFile writes events as newline-delimited JSON and returns the
+// file name
+func writeEventsFile(t *testing.T, events ...*nostr.Event) string {
+	t.Helper()
+	var lines []string
+	for _, event := range events {
+		lines = append(lines, event.String())
+	}
+	file := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestImportContactsFromFile(t *testing.T) {
+	testConfig(t, "")
+	alice := testIdentity("alice")
+	if err := saveIdentities(map[string]Identity{"alice": alice}); err != nil {
+		t.Fatal(err)
+	}
+	_, aliceSK, _ := nip19.Decode(alice.Nsec)
+	bobSK := nostr.GeneratePrivateKey()
+	bob, _ := nostr.GetPublicKey(bobSK)
+	carol := testIdentity("carol").Hex
+
+	follows := &nostr.Event{Kind: nostr.KindFollowList, CreatedAt: nostr.Now(), Tags: nostr.Tags{
+		{"p", bob, "wss://bob.example.com"},
+		{"p", carol, "", "cc"},
+	}}
+	follows.Sign(aliceSK.(string))
+	profile := &nostr.Event{Kind: nostr.KindProfileMetadata, CreatedAt: nostr.Now(), Content: `{"name":"Bob"}`}
+	profile.Sign(bobSK)
+	file := writeEventsFile(t, follows, profile)
+
+	k.Set("file", file)
+	k.Set("dry.run", true)
+	out := captureOutput(t, func() {
+		if err := importContacts(contactImportCmd, []string{"@alice"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "Would import 2 new contacts") {
+		t.Errorf("dry run output:\n%s", out)
+	}
+	if identities, _ := readIdentities(); len(identities) != 1 {
+		t.Fatalf("dry run saved %d identities", len(identities))
+	}
+
+	k.Set("dry.run", false)
+	captureOutput(t, func() {
+		if err := importContacts(contactImportCmd, []string{"@alice"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	identities, _ := readIdentities()
+	if c := identities["bob"]; !c.Contact || c.Hex != bob || !slices.Equal(c.Relays, []string{"wss://bob.example.com"}) {
+		t.Errorf("bob = %+v", c)
+	}
+	if c := identities["cc"]; !c.Contact || c.Hex != carol {
+		t.Errorf("cc = %+v", c)
+	}
+
+	// Importing again updates the same contacts
+	out = captureOutput(t, func() {
+		if err := importContacts(contactImportCmd, []string{"@alice"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "Imported 0 new contacts, 2 already known") {
+		t.Errorf("second import output:\n%s", out)
+	}
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/spf13/cobra"
+
+	"noloc/location"
+)
+
+const (
+	defaultRotation = time.Hour
+	minRotation     = 10 * time.Minute
+
+	// Receivers follow the keys of this many past and future hours
+	ephemeralLookback  = 24 * time.Hour
+	ephemeralLookahead = 24 * time.Hour
+
+	// Renewed subscriptions repeat the events of this last part of a round
+	ephemeralOverlap = time.Hour
+)
+
+var idSeedCmd = &cobra.Command{
+	Use:   "seed <name> [seed]",
+	Short: "Generate or add a seed for ephemeral sender keys",
+	Long: `Store a 32-byte seed shared between a sender and a receiver. The sender
+signs with 'send --ephemeral @name' using a key derived from the seed for the
+current rotation epoch, so consecutive locations cannot be linked to each
+other or to the sender's identity. The receiver stores the same seed and
+rotation and derives the same keys: listen labels events from them with the
+seed name and anon subscribes to them.
+
+Without a seed argument a new seed is generated and printed for sharing
+with the receiver over a secure channel.
+
+--owner names the sender's own identity: its private zones and privacy
+policy apply to what is sent with the seed. Sending with a seed without an
+owner is refused.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: addSeed,
+}
+
+func init() {
+	idCmd.AddCommand(idSeedCmd)
+	idSeedCmd.Flags().Duration("rotation", defaultRotation, "How often the derived sender key changes (at least 10m)")
+	idSeedCmd.Flags().String("owner", "", "Identity (@name) whose private zones and privacy policy apply when sending with the seed")
+}
+
+func addSeed(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	rotation, _ := cmd.Flags().GetDuration("rotation")
+	if rotation < minRotation {
+		return fmt.Errorf("rotation must be at least %s", minRotation)
+	}
+
+	var seed []byte
+	if len(args) == 2 {
+		decoded, err := hex.DecodeString(args[1])
+		if err != nil || len(decoded) != 32 {
+			return fmt.Errorf("invalid seed: must be 64 hex characters")
+		}
+		seed = decoded
+	} else {
+		seed = make([]byte, 32)
+		if _, err := rand.Read(seed); err != nil {
+			return fmt.Errorf("failed to generate seed: %w", err)
+		}
+	}
+
+	identities, err := readIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+	if _, exists := identities[name]; exists {
+		return fmt.Errorf("identity '%s' already exists", name)
+	}
+
+	owner, _ := cmd.Flags().GetString("owner")
+	owner = strings.TrimPrefix(owner, "@")
+	if owner != "" {
+		id, exists := identities[owner]
+		if !exists || id.Contact || id.isSeed() {
+			return fmt.Errorf("owner '%s' is not one of your identities", owner)
+		}
+	}
+
+	identities[name] = Identity{
+		Name:     name,
+		Added:    time.Now().Format("2006-01-02 15:04:05"),
+		Seed:     hex.EncodeToString(seed),
+		Rotation: rotation.String(),
+		Owner:    owner,
+	}
+	if err := saveIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save identities: %w", err)
+	}
+
+	fmt.Printf("Added ephemeral seed '%s' (key rotates every %s)\n", name, rotation)
+	if len(args) == 1 {
+		fmt.Printf("  Seed: %s\n", hex.EncodeToString(seed))
+		fmt.Printf("\nShare it with the receiver, who adds it with:\n")
+		fmt.Printf("  noloc id seed <name> %s --rotation %s\n", hex.EncodeToString(seed), rotation)
+	}
+	if owner == "" {
+		fmt.Printf("\nThe seed has no owner and can only be used to receive (see --owner)\n")
+	}
+	return nil
+}
+
+// ephemeralSeed is a stored seed for ephemeral sender keys
+type ephemeralSeed struct {
+	name     string
+	seed     []byte
+	rotation time.Duration
+	owner    string // Identity name whose zones and policy apply
+}
+
+// seedFromIdentity reads the seed of an identity store entry
+func seedFromIdentity(name string, id Identity) (*ephemeralSeed, error) {
+	seed, err := hex.DecodeString(id.Seed)
+	if err != nil || len(seed) != 32 {
+		return nil, fmt.Errorf("seed '%s' is invalid", name)
+	}
+	rotation := defaultRotation
+	if id.Rotation != "" {
+		if rotation, err = time.ParseDuration(id.Rotation); err != nil {
+			return nil, fmt.Errorf("seed '%s' has an invalid rotation: %w", name, err)
+		}
+	}
+	return &ephemeralSeed{name: name, seed: seed, rotation: rotation, owner: id.Owner}, nil
+}
+
+// senderName returns the identity the seed sends for, whose private zones
+// and privacy policy apply. Without an owner nothing would protect the
+// sender's positions, so sending is refused.
+func (s *ephemeralSeed) senderName() (string, error) {
+	if s.owner == "" {
+		return "", fmt.Errorf("seed '%s' has no owner, so no private zones or privacy policy would apply: add it again with 'noloc id seed %s <seed> --owner @name'", s.name, s.name)
+	}
+	return s.owner, nil
+}
+
+// resolveSeed resolves an @name reference to a stored seed
+func resolveSeed(value string) (*ephemeralSeed, error) {
+	name := strings.TrimPrefix(value, "@")
+	identities, err := seedIdentities()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identities: %w", err)
+	}
+	id, exists := identities[name]
+	if !exists || id.Seed == "" {
+		return nil, fmt.Errorf("seed '%s' not found (add it with 'noloc id seed')", name)
+	}
+	return seedFromIdentity(name, id)
+}
+
+// storedSeeds returns all stored seeds, skipping invalid ones
+func storedSeeds() []*ephemeralSeed {
+	identities, err := seedIdentities()
+	if err != nil {
+		return nil
+	}
+	var seeds []*ephemeralSeed
+	for name, id := range identities {
+		if id.Seed == "" {
+			continue
+		}
+		if seed, err := seedFromIdentity(name, id); err == nil {
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds
+}
+
+// seedIdentities reads the identity store, decrypting it only when it holds
+// encrypted seeds
+func seedIdentities() (map[string]Identity, error) {
+	identities, err := readIdentities()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range identities {
+		if id.SeedNcryptsec != "" {
+			return loadIdentities()
+		}
+	}
+	return identities, nil
+}
+
+// signer returns the signer of the epoch the time falls in
+func (s *ephemeralSeed) signer(t time.Time) (Signer, error) {
+	return skSigner(location.EphemeralKey(s.seed, location.Epoch(t, s.rotation)))
+}
+
+// pubkey returns the public key of an epoch
+func (s *ephemeralSeed) pubkey(epoch int64) string {
+	pubkey, _ := nostr.GetPublicKey(location.EphemeralKey(s.seed, epoch))
+	return pubkey
+}
+
+// owns reports whether an event was signed with a key of this seed. Only
+// the epoch of its created_at and its neighbours are checked.
+func (s *ephemeralSeed) owns(event *nostr.Event) bool {
+	epoch := location.Epoch(event.CreatedAt.Time(), s.rotation)
+	for e := epoch - 1; e <= epoch+1; e++ {
+		if s.pubkey(e) == event.PubKey {
+			return true
+		}
+	}
+	return false
+}
+
+// ephemeralAuthors returns the public keys of all epochs between from and to
+func ephemeralAuthors(seeds []*ephemeralSeed, from, to time.Time) []string {
+	var authors []string
+	for _, s := range seeds {
+		for e := location.Epoch(from, s.rotation); e <= location.Epoch(to, s.rotation); e++ {
+			authors = append(authors, s.pubkey(e))
+		}
+	}
+	return authors
+}
+
+// ephemeralSender signs with keys derived from a seed, rotating every epoch
+// or, per session, keeping the key of the epoch the command started in
+type ephemeralSender struct {
+	seed       *ephemeralSeed
+	session    time.Time
+	perSession bool
+}
+
+func (e *ephemeralSender) signer(now time.Time) (Signer, error) {
+	if e.perSession {
+		return e.seed.signer(e.session)
+	}
+	return e.seed.signer(now)
+}
+
+// subscribeEphemeral subscribes like subscribeWithReconnect with the
+// derived keys of the seeds added to the authors of the first filter. The
+// subscription is renewed before the keys run out, without delivering
+// events twice.
+func subscribeEphemeral(ctx context.Context, relayURL string, filters nostr.Filters, seeds []*ephemeralSeed, handle func(*nostr.Event)) error {
+	if len(seeds) == 0 {
+		return subscribeWithReconnect(ctx, relayURL, filters, handle)
+	}
+
+	filter := filters[0]
+	seen := make(map[string]nostr.Timestamp) // created_at of delivered events
+	var since nostr.Timestamp
+	for {
+		now := time.Now()
+		until := now.Add(ephemeralLookahead)
+
+		round := filter
+		round.Authors = append(append([]string(nil), filter.Authors...), ephemeralAuthors(seeds, now.Add(-ephemeralLookback), until)...)
+		if since > 0 {
+			round.Since = &since
+		}
+		roundFilters := append(nostr.Filters{round}, filters[1:]...)
+
+		roundCtx, cancel := context.WithDeadline(ctx, until)
+		err := subscribeWithReconnect(roundCtx, relayURL, roundFilters, func(event *nostr.Event) {
+			if _, ok := seen[event.ID]; ok {
+				return
+			}
+			seen[event.ID] = event.CreatedAt
+			handle(event)
+		})
+		cancel()
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+		// Overlap with the last round, seen drops events delivered twice.
+		// Events before the overlap are not sent again and are forgotten.
+		since = nostr.Timestamp(until.Add(-ephemeralOverlap).Unix())
+		pruneSeen(seen, since)
+	}
+}
+
+// pruneSeen forgets the delivered events created before since
+func pruneSeen(seen map[string]nostr.Timestamp, since nostr.Timestamp) {
+	for id, createdAt := range seen {
+		if createdAt < since {
+			delete(seen, id)
+		}
+	}
+}
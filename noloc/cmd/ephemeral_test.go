@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const testSeed = "8f4e1f2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7"
+
+func TestSeedEncryptedStore(t *testing.T) {
+	testConfig(t, "")
+	k.Set("passphrase", testPassphrase)
+	writeEncryptedStore(t, map[string]Identity{"alice": testIdentity("alice")})
+
+	if err := addSeed(idSeedCmd, []string{"courier", testSeed}); err != nil {
+		t.Fatal(err)
+	}
+	if file := readStoreFile(t); strings.Contains(file, testSeed) {
+		t.Fatalf("identity file contains the plaintext seed:\n%s", file)
+	}
+	stored, err := readIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored["courier"].SeedNcryptsec == "" {
+		t.Fatalf("seed not encrypted: %+v", stored["courier"])
+	}
+
+	// A new process unlocks the store for the seed
+	storePassphrase = ""
+	seed, err := resolveSeed("@courier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(seed.seed) != testSeed {
+		t.Errorf("seed = %x, want %s", seed.seed, testSeed)
+	}
+	if seeds := storedSeeds(); len(seeds) != 1 || hex.EncodeToString(seeds[0].seed) != testSeed {
+		t.Errorf("stored seeds = %v", seeds)
+	}
+
+	if _, err := identitySigner("courier", stored["courier"]); err == nil || !strings.Contains(err.Error(), "ephemeral seed") {
+		t.Errorf("identitySigner err = %v, want ephemeral seed", err)
+	}
+}
+
+func TestSeedOwner(t *testing.T) {
+	testConfig(t, "")
+	bob := testIdentity("bob")
+	bob.Contact = true
+	if err := saveIdentities(map[string]Identity{"alice": testIdentity("alice"), "bob": bob}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { idSeedCmd.Flags().Set("owner", "") })
+
+	for _, owner := range []string{"@bob", "@nobody"} {
+		idSeedCmd.Flags().Set("owner", owner)
+		captureOutput(t, func() {
+			if err := addSeed(idSeedCmd, []string{"courier", testSeed}); err == nil {
+				t.Errorf("owner %s accepted", owner)
+			}
+		})
+	}
+
+	idSeedCmd.Flags().Set("owner", "@alice")
+	captureOutput(t, func() {
+		if err := addSeed(idSeedCmd, []string{"courier", testSeed}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	seed, err := resolveSeed("@courier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := seed.senderName(); err != nil || name != "alice" {
+		t.Errorf("sender = %q, %v, want alice", name, err)
+	}
+}
+
+func TestShowIdentitySeed(t *testing.T) {
+	testConfig(t, "")
+	k.Set("passphrase", testPassphrase)
+	writeEncryptedStore(t, map[string]Identity{"courier": {Name: "courier", Seed: testSeed, Rotation: "1h0m0s"}})
+
+	hidden := captureOutput(t, func() {
+		if err := showIdentity(idShowCmd, []string{"courier"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(hidden, testSeed) || !strings.Contains(hidden, "--show-seed") {
+		t.Errorf("seed shown without --show-seed:\n%s", hidden)
+	}
+
+	idShowCmd.Flags().Set("show-seed", "true")
+	t.Cleanup(func() { idShowCmd.Flags().Set("show-seed", "false") })
+	shown := captureOutput(t, func() {
+		if err := showIdentity(idShowCmd, []string{"courier"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(shown, testSeed) {
+		t.Errorf("seed not shown with --show-seed:\n%s", shown)
+	}
+}
+
+func TestPruneSeen(t *testing.T) {
+	seen := map[string]nostr.Timestamp{"old": 100, "edge": 200, "new": 300}
+	pruneSeen(seen, 200)
+	if _, ok := seen["old"]; ok || len(seen) != 2 {
+		t.Errorf("seen = %v, want edge and new", seen)
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"noloc/location"
+)
+
+// geoFilter restricts received locations to a bounding box or a circle
+type geoFilter struct {
+	box    location.BoundingBox
+	lat    float64 // Circle center
+	lon    float64
+	radius float64 // Circle radius in meters, 0 = box only
+}
+
+// addGeoFlags registers the geographic filter flags on a command
+func addGeoFlags(cmd *cobra.Command) {
+	cmd.Flags().String("near", "", "Only locations near this point (lat,lon), use with --radius")
+	cmd.Flags().Float64("radius", 0, "Radius around --near in kilometers")
+	cmd.Flags().String("bbox", "", "Only locations inside this box (min_lat,min_lon,max_lat,max_lon)")
+}
+
+// parseGeoFilter reads the geographic filter flags, nil if none are set
+func parseGeoFilter() (*geoFilter, error) {
+	near := k.String("near")
+	bbox := k.String("bbox")
+	radiusKm := k.Float64("radius")
+
+	switch {
+	case near != "" && bbox != "":
+		return nil, fmt.Errorf("--near and --bbox cannot be used together")
+
+	case near != "":
+		if radiusKm <= 0 {
+			return nil, fmt.Errorf("--radius must be positive when --near is set")
+		}
+		lat, lon, err := parseLatLon(near)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --near: %w", err)
+		}
+		radius := radiusKm * 1000
+		return &geoFilter{
+			box:    location.CircleBox(lat, lon, radius),
+			lat:    lat,
+			lon:    lon,
+			radius: radius,
+		}, nil
+
+	case bbox != "":
+		values, err := parseFloats(bbox, 4)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --bbox: %w", err)
+		}
+		box := location.BoundingBox{MinLat: values[0], MinLon: values[1], MaxLat: values[2], MaxLon: values[3]}
+		if box.MinLat > box.MaxLat || !validLatLon(box.MinLat, box.MinLon) || !validLatLon(box.MaxLat, box.MaxLon) {
+			return nil, fmt.Errorf("invalid --bbox: expected min_lat,min_lon,max_lat,max_lon")
+		}
+		return &geoFilter{box: box}, nil
+
+	case radiusKm != 0:
+		return nil, fmt.Errorf("--radius requires --near")
+	}
+
+	return nil, nil
+}
+
+// prefixes returns the geohash cover set for #g subscriptions
+func (g *geoFilter) prefixes() []string {
+	return location.CoverBox(g.box, location.DefaultMaxCells)
+}
+
+// contains reports whether the geohash cell overlaps the filter, so that
+// a coarse location is kept when it may lie inside the area
+func (g *geoFilter) contains(gh string) bool {
+	if !location.ValidGeohash(gh) {
+		return false
+	}
+	cell := location.GeohashBox(gh)
+	if g.radius > 0 {
+		lat, lon := cell.Nearest(g.lat, g.lon)
+		return location.Distance(g.lat, g.lon, lat, lon) <= g.radius
+	}
+	return g.box.Intersects(cell)
+}
+
+func (g *geoFilter) String() string {
+	if g.radius > 0 {
+		return fmt.Sprintf("within %.1f km of %.6f,%.6f", g.radius/1000, g.lat, g.lon)
+	}
+	return fmt.Sprintf("inside %.6f,%.6f,%.6f,%.6f", g.box.MinLat, g.box.MinLon, g.box.MaxLat, g.box.MaxLon)
+}
+
+// parseLatLon parses a "lat,lon" pair in decimal degrees
+func parseLatLon(value string) (float64, float64, error) {
+	values, err := parseFloats(value, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !validLatLon(values[0], values[1]) {
+		return 0, 0, fmt.Errorf("coordinates out of range: %s", value)
+	}
+	return values[0], values[1], nil
+}
+
+func parseFloats(value string, count int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d comma-separated numbers, got %q", count, value)
+	}
+
+	values := make([]float64, count)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", part)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func validLatLon(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// formatDistance formats meters as m below one kilometer and km above
+func formatDistance(meters float64) string {
+	if meters < 1000 {
+		return fmt.Sprintf("%.0f m", meters)
+	}
+	return fmt.Sprintf("%.1f km", meters/1000)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"testing"
+
+	"noloc/location"
+)
+
+func TestGeoFilterContains(t *testing.T) {
+	// 5 km around Helsinki railway station
+	near := &geoFilter{box: location.CircleBox(60.1719, 24.9414, 5000), lat: 60.1719, lon: 24.9414, radius: 5000}
+	box := &geoFilter{box: location.BoundingBox{MinLat: 60.1, MinLon: 24.8, MaxLat: 60.3, MaxLon: 25.1}}
+
+	tests := []struct {
+		name   string
+		filter *geoFilter
+		gh     string
+		want   bool
+	}{
+		{"near full length", near, "ud9wrzgsmj1b", true},
+		{"near coarse cell around it", near, "ud9", true},
+		{"near coarse cell whose center is outside", near, "ud9w", true},
+		{"near far away", near, "u4pruydqqvj", false},
+		{"near coarse cell beside it", near, "ud3", false},
+		{"box full length", box, "ud9wrzgsmj1b", true},
+		{"box coarse cell", box, "u", true},
+		{"box far away", box, "u4pruydqqvj", false},
+		{"invalid", box, "UD9W", false},
+		{"empty", box, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.contains(tt.gh); got != tt.want {
+			t.Errorf("%s: contains(%q) = %v, want %v", tt.name, tt.gh, got, tt.want)
+		}
+	}
+}
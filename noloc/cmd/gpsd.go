@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	defaultGPSDAddress = "localhost:2947"
+	gpsdWatch          = `?WATCH={"enable":true,"json":true};` + "\n"
+	gpsdDialTimeout    = 10 * time.Second
+	gpsdRetryDelay     = 5 * time.Second
+	gpsdTrack          = "gps" // d-tag of the shared positions
+)
+
+// gpsdReport holds the fields of a gpsd JSON report used here. Only TPV
+// (time-position-velocity) reports with a fix are read.
+type gpsdReport struct {
+	Class  string   `json:"class"`
+	Device string   `json:"device"`
+	Mode   int      `json:"mode"` // 0 and 1 = no fix, 2 = 2D, 3 = 3D
+	Time   string   `json:"time"`
+	Lat    *float64 `json:"lat"`
+	Lon    *float64 `json:"lon"`
+	Alt    *float64 `json:"alt"`    // Meters, replaced by altMSL in gpsd 3.20
+	AltMSL *float64 `json:"altMSL"` // Meters above mean sea level
+	Speed  *float64 `json:"speed"`  // Meters per second
+	Track  *float64 `json:"track"`  // Course over ground, degrees from true north
+	Epx    *float64 `json:"epx"`    // Longitude error in meters, 95% confidence
+	Epy    *float64 `json:"epy"`    // Latitude error in meters, 95% confidence
+}
+
+// gpsdSource reads fixes from gpsd over its JSON protocol, reconnecting
+// when the connection is lost
+type gpsdSource struct {
+	address    string
+	device     string // Only fixes of this device, "" = all
+	conn       net.Conn
+	reader     *bufio.Reader
+	stop       func() bool   // Stops closing conn when the context is done
+	retry      time.Time     // Earliest time to connect again
+	retryDelay time.Duration // Wait after a failed or lost connection
+}
+
+func newGPSDSource() (PositionSource, error) {
+	address := k.String("gpsd.address")
+	if address == "" {
+		address = defaultGPSDAddress
+	}
+	return &gpsdSource{address: address, device: k.String("gpsd.device"), retryDelay: gpsdRetryDelay}, nil
+}
+
+func (s *gpsdSource) Next(ctx context.Context) (*Position, error) {
+	if s.conn == nil {
+		if err := sleepContext(ctx, time.Until(s.retry)); err != nil {
+			return nil, err
+		}
+		if err := s.connect(ctx); err != nil {
+			s.retry = time.Now().Add(s.retryDelay)
+			return nil, err
+		}
+	}
+
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil {
+			// Not wrapped, io.EOF would end sharing instead of reconnecting
+			s.close()
+			return nil, fmt.Errorf("lost connection to gpsd: %v", err)
+		}
+
+		var report gpsdReport
+		if err := json.Unmarshal(line, &report); err != nil {
+			continue
+		}
+		if s.device != "" && report.Device != s.device {
+			continue
+		}
+		if position := report.position(); position != nil {
+			return position, nil
+		}
+	}
+}
+
+// connect connects to gpsd and asks it to stream JSON reports. The
+// connection is closed when the context is done, ending a blocked read.
+func (s *gpsdSource) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: gpsdDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to gpsd at %s: %w", s.address, err)
+	}
+	if _, err := conn.Write([]byte(gpsdWatch)); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start gpsd watch: %w", err)
+	}
+
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	s.stop = context.AfterFunc(ctx, func() { conn.Close() })
+	return nil
+}
+
+// close drops a lost connection. A gpsd that closes connections right
+// after accepting them is not reconnected to before the retry delay.
+func (s *gpsdSource) close() {
+	s.stop()
+	s.conn.Close()
+	s.conn = nil
+	s.retry = time.Now().Add(s.retryDelay)
+}
+
+// position turns a TPV report with a fix into a position, nil for other
+// reports. The 95% error estimates epx and epy become the accuracy, the
+// 68% radius of the horizontal error.
+func (r *gpsdReport) position() *Position {
+	if r.Class != "TPV" || r.Mode < 2 || r.Lat == nil || r.Lon == nil {
+		return nil
+	}
+
+	p := &Position{Track: gpsdTrack, Lat: *r.Lat, Lon: *r.Lon}
+	if t, err := time.Parse(time.RFC3339, r.Time); err == nil {
+		p.Time = t
+	}
+	if r.Epx != nil && r.Epy != nil {
+		p.Accuracy = math.Hypot(*r.Epx, *r.Epy) / 1.96
+	}
+
+	alt := r.AltMSL
+	if alt == nil {
+		alt = r.Alt
+	}
+	if alt != nil && r.Mode == 3 {
+		p.Details.Extra = append(p.Details.Extra, nostr.Tag{"altitude", strconv.FormatFloat(*alt, 'f', 0, 64)})
+	}
+	if r.Speed != nil {
+		// km/h like the speed tag of trains
+		p.Details.Extra = append(p.Details.Extra, nostr.Tag{"speed", strconv.FormatFloat(*r.Speed*3.6, 'f', 0, 64)})
+	}
+	if r.Track != nil {
+		p.Details.Extra = append(p.Details.Extra, nostr.Tag{"heading", strconv.FormatFloat(*r.Track, 'f', 0, 64)})
+	}
+	return p
+}
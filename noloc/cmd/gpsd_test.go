@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// startFakeGPSD serves one script of report lines per connection, in
+// order, and closes each connection after its script. It sends the WATCH
+// command of each connection and the time it was accepted.
+func startFakeGPSD(t *testing.T, scripts ...[]string) (string, <-chan string, <-chan time.Time) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	watches := make(chan string, len(scripts))
+	accepted := make(chan time.Time, len(scripts))
+	go func() {
+		for _, script := range scripts {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- time.Now()
+			watch, _ := bufio.NewReader(conn).ReadString('\n')
+			watches <- watch
+			for _, line := range script {
+				conn.Write([]byte(line + "\n"))
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String(), watches, accepted
+}
+
+func TestGPSDSource(t *testing.T) {
+	tpv := `{"class":"TPV","device":"/dev/ttyUSB0","mode":3,"time":"2024-05-01T12:00:00.000Z","lat":60.1699,"lon":24.9384,"altMSL":15.4,"speed":10,"track":271.6,"epx":6,"epy":8}`
+	address, watches, accepted := startFakeGPSD(t,
+		[]string{
+			`{"class":"VERSION","release":"3.25"}`,
+			`not json`,
+			`{"class":"TPV","device":"/dev/ttyUSB0","mode":1}`,
+			`{"class":"TPV","device":"/dev/ttyACM0","mode":2,"lat":1,"lon":2}`,
+			tpv,
+		},
+		[]string{tpv},
+	)
+
+	ctx := context.Background()
+	source := &gpsdSource{address: address, device: "/dev/ttyUSB0", retryDelay: 200 * time.Millisecond}
+
+	p, err := source.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watch := <-watches; watch != gpsdWatch {
+		t.Errorf("watch = %q, want %q", watch, gpsdWatch)
+	}
+	// The report without a fix and the one of another device are skipped
+	if p.Track != gpsdTrack || p.Lat != 60.1699 || p.Lon != 24.9384 {
+		t.Errorf("position = %+v", p)
+	}
+	if !p.Time.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("time = %s", p.Time)
+	}
+	if math.Abs(p.Accuracy-10/1.96) > 1e-9 {
+		t.Errorf("accuracy = %v, want %v", p.Accuracy, 10/1.96)
+	}
+	want := nostr.Tags{{"altitude", "15"}, {"speed", "36"}, {"heading", "272"}}
+	if !slices.EqualFunc(p.Details.Extra, want, slices.Equal) {
+		t.Errorf("extra = %v, want %v", p.Details.Extra, want)
+	}
+
+	// gpsd closes the connection
+	if _, err := source.Next(ctx); err == nil || !strings.Contains(err.Error(), "lost connection") {
+		t.Fatalf("err = %v, want lost connection", err)
+	}
+	lost := time.Now()
+	<-accepted
+
+	if _, err := source.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if waited := (<-accepted).Sub(lost); waited < source.retryDelay-10*time.Millisecond {
+		t.Errorf("reconnected after %s, want the retry delay %s", waited, source.retryDelay)
+	}
+}
+
+func TestGPSDSourceCanceled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// Accept and stay silent
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	source := &gpsdSource{address: listener.Addr().String(), retryDelay: time.Hour}
+	if _, err := source.Next(ctx); err == nil {
+		t.Fatal("want an error when the context is done during a read")
+	}
+	if _, err := source.Next(ctx); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want the context error while waiting to retry", err)
+	}
+}
+
+func TestGPSDReportPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		report   string
+		nilPos   bool
+		accuracy float64
+		extra    nostr.Tags
+	}{
+		{name: "sky report", report: `{"class":"SKY","mode":3,"lat":1,"lon":2}`, nilPos: true},
+		{name: "no fix", report: `{"class":"TPV","mode":0,"lat":1,"lon":2}`, nilPos: true},
+		{name: "mode 1", report: `{"class":"TPV","mode":1,"lat":1,"lon":2}`, nilPos: true},
+		{name: "no latitude", report: `{"class":"TPV","mode":3,"lon":2}`, nilPos: true},
+		{name: "2D fix without altitude", report: `{"class":"TPV","mode":2,"lat":1,"lon":2,"alt":100}`},
+		{name: "alt before gpsd 3.20", report: `{"class":"TPV","mode":3,"lat":1,"lon":2,"alt":99.6}`, extra: nostr.Tags{{"altitude", "100"}}},
+		{name: "altMSL over alt", report: `{"class":"TPV","mode":3,"lat":1,"lon":2,"alt":99.6,"altMSL":50}`, extra: nostr.Tags{{"altitude", "50"}}},
+		{name: "one error estimate", report: `{"class":"TPV","mode":2,"lat":1,"lon":2,"epx":5}`},
+		{name: "error estimates", report: `{"class":"TPV","mode":2,"lat":1,"lon":2,"epx":0,"epy":19.6}`, accuracy: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report gpsdReport
+			if err := json.Unmarshal([]byte(tt.report), &report); err != nil {
+				t.Fatal(err)
+			}
+			p := report.position()
+			if tt.nilPos {
+				if p != nil {
+					t.Errorf("position = %+v, want nil", p)
+				}
+				return
+			}
+			if p == nil {
+				t.Fatal("position = nil")
+			}
+			if p.Lat != 1 || p.Lon != 2 || math.Abs(p.Accuracy-tt.accuracy) > 1e-9 {
+				t.Errorf("position = %+v", p)
+			}
+			if !slices.EqualFunc(p.Details.Extra, tt.extra, slices.Equal) {
+				t.Errorf("extra = %v, want %v", p.Details.Extra, tt.extra)
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip17"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip59"
+	"github.com/spf13/cobra"
+)
+
+// groupKeyTag marks a NIP-17 message carrying a group key:
+// ["noloc-group", <name>, <nsec>]
+const groupKeyTag = "noloc-group"
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage shared group keys",
+	Long: `Manage groups whose key is shared by all members. A group is stored
+as an identity with a member list, so @group works wherever an identity
+reference is accepted: send --receiver @group encrypts to the group key and
+listen --receiver @group decrypts with it.
+
+The group nsec is distributed to members as NIP-17 private direct messages
+(NIP-44 encrypted and gift wrapped), which members import with
+'noloc group receive'.`,
+}
+
+var groupCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Generate a new group key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  createGroup,
+}
+
+var groupAddMemberCmd = &cobra.Command{
+	Use:   "add-member <name> <npub|@name>...",
+	Short: "Add members to a group, sending them the key with --sender",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  addGroupMembers,
+}
+
+var groupExportCmd = &cobra.Command{
+	Use:   "export <name>",
+	Short: "Send the group key to members over NIP-17 direct messages",
+	Args:  cobra.ExactArgs(1),
+	RunE:  exportGroup,
+}
+
+var groupRotateCmd = &cobra.Command{
+	Use:   "rotate <name>",
+	Short: "Replace the group key, optionally removing members",
+	Long: `Generate a new key for the group and send it to the remaining members
+when --sender is given. Locations shared with the old key can no longer be
+read with the group, removed members do not receive the new key.`,
+	Args: cobra.ExactArgs(1),
+	RunE: rotateGroup,
+}
+
+var groupReceiveCmd = &cobra.Command{
+	Use:   "receive",
+	Short: "Import group keys sent to you over NIP-17 direct messages",
+	Long: `Fetch NIP-17 direct messages addressed to --receiver from the relay and
+store the group keys they carry. A group that already exists is only
+updated by the member who originally shared it.
+
+Keys from your contacts and identities are imported, keys from anyone else
+only after confirming them. With --from only keys from those senders are
+imported, without asking.`,
+	RunE: receiveGroups,
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupCreateCmd)
+	groupCmd.AddCommand(groupAddMemberCmd)
+	groupCmd.AddCommand(groupExportCmd)
+	groupCmd.AddCommand(groupRotateCmd)
+	groupCmd.AddCommand(groupReceiveCmd)
+
+	groupCreateCmd.Flags().StringSlice("member", nil, "Group members (npub, nprofile, hex, user@domain or @identity)")
+	groupAddMemberCmd.Flags().StringP("sender", "s", "", "Send the key to the new members from this identity (nsec..., bunker:// URI or @identity)")
+	groupExportCmd.Flags().StringP("sender", "s", "", "Sender private key for the direct messages (nsec..., bunker:// URI or @identity)")
+	groupExportCmd.Flags().StringSlice("member", nil, "Only send to these members (default all)")
+	groupExportCmd.MarkFlagRequired("sender")
+	groupRotateCmd.Flags().StringP("sender", "s", "", "Send the new key to the members from this identity (nsec..., bunker:// URI or @identity)")
+	groupRotateCmd.Flags().StringSlice("remove", nil, "Members to remove before rotating")
+	groupReceiveCmd.Flags().StringP("receiver", "r", "", "Receiver private key (nsec..., bunker:// URI or @identity)")
+	groupReceiveCmd.Flags().StringSlice("from", nil, "Only import keys sent by these members (npub, nprofile, hex, user@domain or @identity)")
+	groupReceiveCmd.MarkFlagRequired("receiver")
+}
+
+// loadGroup returns the stored group with the given name
+func loadGroup(identities map[string]Identity, name string) (Identity, error) {
+	name = strings.TrimPrefix(name, "@")
+	group, exists := identities[name]
+	if !exists {
+		return Identity{}, fmt.Errorf("group '%s' not found", name)
+	}
+	if !group.Group {
+		return Identity{}, fmt.Errorf("identity '%s' is not a group", name)
+	}
+	return group, nil
+}
+
+// newGroupKey generates a key pair for a group
+func newGroupKey(name string, members []string) Identity {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	nsec, _ := nip19.EncodePrivateKey(sk)
+	npub, _ := nip19.EncodePublicKey(pk)
+
+	return Identity{
+		Name:    name,
+		Nsec:    nsec,
+		Npub:    npub,
+		Hex:     pk,
+		Added:   time.Now().Format("2006-01-02 15:04:05"),
+		Group:   true,
+		Members: members,
+	}
+}
+
+// resolveMembers resolves member references to hex public keys
+func resolveMembers(inputs []string) ([]string, error) {
+	var members []string
+	for _, input := range inputs {
+		pubkey, err := resolvePubkey(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve member %s: %w", input, err)
+		}
+		members = append(members, pubkey)
+	}
+	return members, nil
+}
+
+// addMembers appends members that are not in the list yet and returns the
+// new list and the added members
+func addMembers(members, pubkeys []string) ([]string, []string) {
+	var added []string
+	for _, pubkey := range pubkeys {
+		if !containsString(members, pubkey) && !containsString(added, pubkey) {
+			added = append(added, pubkey)
+		}
+	}
+	return append(members, added...), added
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func createGroup(cmd *cobra.Command, args []string) error {
+	LoadFlags(cmd)
+	name := args[0]
+
+	identities, err := readIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+	if _, exists := identities[name]; exists {
+		return fmt.Errorf("identity '%s' already exists", name)
+	}
+
+	pubkeys, err := resolveMembers(stringList("member"))
+	if err != nil {
+		return err
+	}
+	members, _ := addMembers(nil, pubkeys)
+
+	group := newGroupKey(name, members)
+	identities[name] = group
+	if err := saveIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save group: %w", err)
+	}
+
+	fmt.Printf("Created group '%s'\n", name)
+	fmt.Printf("  Npub: %s\n", group.Npub)
+	fmt.Printf("  Members: %d\n", len(members))
+	fmt.Printf("\nSend the key to the members with: noloc group export %s --sender @you\n", name)
+
+	return nil
+}
+
+func addGroupMembers(cmd *cobra.Command, args []string) error {
+	LoadFlags(cmd)
+
+	identities, err := readIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+	group, err := loadGroup(identities, args[0])
+	if err != nil {
+		return err
+	}
+
+	pubkeys, err := resolveMembers(args[1:])
+	if err != nil {
+		return err
+	}
+
+	var added []string
+	group.Members, added = addMembers(group.Members, pubkeys)
+	identities[group.Name] = group
+	if err := saveIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save group: %w", err)
+	}
+
+	fmt.Printf("Added %d members to group '%s' (%d members)\n", len(added), group.Name, len(group.Members))
+
+	if k.String("sender") == "" || len(added) == 0 {
+		return nil
+	}
+
+	// The key is decrypted only to send it, the store was saved as read
+	decrypted, err := loadIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+	group, err = loadGroup(decrypted, group.Name)
+	if err != nil {
+		return err
+	}
+	return sendGroupKey(k.String("sender"), group, added)
+}
+
+func exportGroup(cmd *cobra.Command, args []string) error {
+	LoadFlags(cmd)
+
+	identities, err := loadIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+	group, err := loadGroup(identities, args[0])
+	if err != nil {
+		return err
+	}
+
+	members := group.Members
+	if selected := stringList("member"); len(selected) > 0 {
+		members, err = resolveMembers(selected)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			if !containsString(group.Members, member) {
+				return fmt.Errorf("%s is not a member of group '%s'", member, group.Name)
+			}
+		}
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("group '%s' has no members, add them with 'noloc group add-member'", group.Name)
+	}
+
+	return sendGroupKey(k.String("sender"), group, members)
+}
+
+func rotateGroup(cmd *cobra.Command, args []string) error {
+	LoadFlags(cmd)
+
+	identities, err := readIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+	group, err := loadGroup(identities, args[0])
+	if err != nil {
+		return err
+	}
+
+	removed, err := resolveMembers(stringList("remove"))
+	if err != nil {
+		return err
+	}
+	var members []string
+	for _, member := range group.Members {
+		if !containsString(removed, member) {
+			members = append(members, member)
+		}
+	}
+
+	rotated := newGroupKey(group.Name, members)
+	identities[group.Name] = rotated
+	if err := saveIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save group: %w", err)
+	}
+
+	fmt.Printf("Rotated key of group '%s'\n", group.Name)
+	fmt.Printf("  Old npub: %s\n", group.Npub)
+	fmt.Printf("  New npub: %s\n", rotated.Npub)
+	fmt.Printf("  Members: %d (%d removed)\n", len(members), len(group.Members)-len(members))
+
+	if k.String("sender") == "" || len(members) == 0 {
+		return nil
+	}
+	return sendGroupKey(k.String("sender"), rotated, members)
+}
+
+// sendGroupKey sends the group nsec to each member as a NIP-17 direct
+// message and prints which members got it
+func sendGroupKey(sender string, group Identity, members []string) error {
+	signer, err := resolveSigner(sender)
+	if err != nil {
+		return fmt.Errorf("failed to resolve sender: %w", err)
+	}
+
+	relayURLs, err := relayURLs()
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("noloc group key for '%s': %s\nImport it with: noloc group receive --receiver <your identity>", group.Name, group.Nsec)
+	tags := nostr.Tags{{groupKeyTag, group.Name, group.Nsec}}
+
+	defer pool.close()
+	ctx := context.Background()
+	failed := 0
+	for _, member := range members {
+		label, _ := nip19.EncodePublicKey(member)
+
+		_, toMember, err := nip17.PrepareMessage(ctx, content, tags, signer, member, nil)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", label, err)
+			failed++
+			continue
+		}
+
+		results, err := publishToRelays(relayURLs, &toMember)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", label, err)
+			failed++
+			continue
+		}
+		fmt.Printf("✓ %s: %d/%d relays accepted\n", label, countAccepted(results), len(results))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to send group key to %d of %d members", failed, len(members))
+	}
+	fmt.Printf("Sent key of group '%s' to %d members\n", group.Name, len(members))
+	return nil
+}
+
+func receiveGroups(cmd *cobra.Command, args []string) error {
+	LoadFlags(cmd)
+
+	signer, err := resolveSigner(k.String("receiver"))
+	if err != nil {
+		return fmt.Errorf("failed to resolve receiver: %w", err)
+	}
+	receiverPubkey := signer.PublicKey()
+
+	from, err := resolveMembers(stringList("from"))
+	if err != nil {
+		return err
+	}
+
+	relayURL, err := primaryRelayURL()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	relay, err := nostr.RelayConnect(ctx, relayURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to relay: %w", err)
+	}
+	defer relay.Close()
+
+	wraps, err := relay.QuerySync(ctx, nostr.Filter{
+		Kinds: []int{nostr.KindGiftWrap},
+		Tags:  nostr.TagMap{"p": []string{receiverPubkey}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query relay: %w", err)
+	}
+
+	// Apply keys oldest first so the latest rotation wins
+	var messages []nostr.Event
+	for _, wrap := range wraps {
+		rumor, err := nip59.GiftUnwrap(*wrap, func(otherPubkey, ciphertext string) (string, error) {
+			return signer.Decrypt(ctx, ciphertext, otherPubkey)
+		})
+		if err != nil || rumor.Kind != nostr.KindDirectMessage {
+			continue
+		}
+		messages = append(messages, rumor)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].CreatedAt < messages[j].CreatedAt })
+
+	identities, err := readIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+
+	imported := 0
+	for _, message := range messages {
+		tag := message.Tags.Find(groupKeyTag)
+		if len(tag) < 3 {
+			continue
+		}
+		if !trustGroupSender(identities, from, tag[1], message.PubKey) {
+			log.Printf("Skipping group key '%s' from %s", tag[1], message.PubKey)
+			continue
+		}
+		ok, err := importGroupKey(identities, tag[1], tag[2], message.PubKey)
+		if err != nil {
+			log.Printf("Skipping group key from %s: %v", message.PubKey, err)
+			continue
+		}
+		if ok {
+			imported++
+		}
+	}
+
+	if imported == 0 {
+		fmt.Println("No new group keys found.")
+		return nil
+	}
+	if err := saveIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save identities: %w", err)
+	}
+	return nil
+}
+
+// trustGroupSender reports whether a group key from the sender may be
+// imported: only senders in --from when it is set, else known contacts and
+// identities, or anyone else after confirming
+func trustGroupSender(identities map[string]Identity, from []string, name, sender string) bool {
+	if len(from) > 0 {
+		return containsString(from, sender)
+	}
+	for _, id := range identities {
+		if id.Hex == sender && !id.Group {
+			return true
+		}
+	}
+
+	npub, _ := nip19.EncodePublicKey(sender)
+	return confirm(fmt.Sprintf("Import group '%s' from unknown sender %s? [y/N] ", name, npub))
+}
+
+// importGroupKey stores a received group key and reports whether it was
+// new. Existing groups are only replaced by keys from the member who shared
+// them first.
+func importGroupKey(identities map[string]Identity, name, nsec, admin string) (bool, error) {
+	_, skRaw, err := nip19.Decode(nsec)
+	if err != nil {
+		return false, fmt.Errorf("invalid group nsec: %w", err)
+	}
+	pk, err := nostr.GetPublicKey(skRaw.(string))
+	if err != nil {
+		return false, fmt.Errorf("invalid group key: %w", err)
+	}
+
+	if existing, exists := identities[name]; exists {
+		switch {
+		case existing.Hex == pk:
+			return false, nil
+		case !existing.Group:
+			return false, fmt.Errorf("identity '%s' already exists and is not a group", name)
+		case existing.Admin != admin:
+			return false, fmt.Errorf("group '%s' was shared by someone else", name)
+		}
+	}
+
+	npub, _ := nip19.EncodePublicKey(pk)
+	identities[name] = Identity{
+		Name:  name,
+		Nsec:  nsec,
+		Npub:  npub,
+		Hex:   pk,
+		Added: time.Now().Format("2006-01-02 15:04:05"),
+		Group: true,
+		Admin: admin,
+	}
+	fmt.Printf("Imported group '%s' (%s)\n", name, npub)
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrustGroupSender(t *testing.T) {
+	bob := testIdentity("bob")
+	contact := Identity{Name: "carol", Npub: "npub1carol", Hex: strings.Repeat("c", 64), Contact: true}
+	group := testIdentity("team")
+	group.Group = true
+	identities := map[string]Identity{"bob": bob, "carol": contact, "team": group}
+	stranger := strings.Repeat("d", 64)
+
+	tests := []struct {
+		name   string
+		from   []string
+		sender string
+		input  string
+		want   bool
+	}{
+		{"own identity", nil, bob.Hex, "", true},
+		{"contact", nil, contact.Hex, "", true},
+		{"group key as sender", nil, group.Hex, "", false},
+		{"stranger confirmed", nil, stranger, "y\n", true},
+		{"stranger confirmed with yes", nil, stranger, "YES\n", true},
+		{"stranger declined", nil, stranger, "n\n", false},
+		{"stranger without input", nil, stranger, "", false},
+		{"in --from", []string{stranger}, stranger, "", true},
+		{"contact not in --from", []string{stranger}, contact.Hex, "y\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConfig(t, tt.input)
+			if got := trustGroupSender(identities, tt.from, "team", tt.sender); got != tt.want {
+				t.Errorf("trustGroupSender = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddGroupMembersKeepsStoreEncrypted(t *testing.T) {
+	testConfig(t, "")
+	k.Set("passphrase", testPassphrase)
+
+	group := newGroupKey("team", nil)
+	identities := map[string]Identity{"alice": testIdentity("alice"), "team": group}
+	if err := encryptIdentities(identities, testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeIdentities(identities); err != nil {
+		t.Fatal(err)
+	}
+
+	member := testIdentity("bob")
+	if err := addGroupMembers(groupAddMemberCmd, []string{"team", member.Npub}); err != nil {
+		t.Fatal(err)
+	}
+
+	if file := readStoreFile(t); strings.Contains(file, "nsec1") {
+		t.Errorf("identity file contains a plaintext nsec:\n%s", file)
+	}
+	stored, err := readIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members := stored["team"].Members; len(members) != 1 || members[0] != member.Hex {
+		t.Errorf("members = %v, want [%s]", members, member.Hex)
+	}
+	if stored["team"].Ncryptsec != identities["team"].Ncryptsec {
+		t.Errorf("group ncryptsec changed")
+	}
+}
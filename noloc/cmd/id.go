@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -17,10 +18,36 @@ import (
 
 type Identity struct {
 	Name  string `json:"name"`
-	Nsec  string `json:"nsec"`
+	Nsec  string `json:"nsec,omitempty"`
 	Npub  string `json:"npub"`
 	Hex   string `json:"hex"`
 	Added string `json:"added"`
+
+	// NIP-49 encrypted nsec, stored instead of Nsec in an encrypted store
+	Ncryptsec string `json:"ncryptsec,omitempty"`
+
+	// NIP-46 remote signer holding the key instead of Nsec
+	Bunker       string `json:"bunker,omitempty"`        // bunker:// URI without the connect secret
+	BunkerClient string `json:"bunker_client,omitempty"` // Client secret key (hex) the signer authorized
+
+	// NIP-49 encrypted BunkerClient, stored instead of it in an encrypted store
+	BunkerClientNcryptsec string `json:"bunker_client_ncryptsec,omitempty"`
+
+	// Watch-only contacts have only a public key, Name is their petname
+	Contact bool     `json:"contact,omitempty"`
+	Note    string   `json:"note,omitempty"`
+	Relays  []string `json:"relays,omitempty"` // Preferred relays of the contact
+
+	// Seed for ephemeral sender keys shared with a receiver, no key pair
+	Seed          string `json:"seed,omitempty"`           // 32 bytes hex
+	SeedNcryptsec string `json:"seed_ncryptsec,omitempty"` // NIP-49 encrypted Seed in an encrypted store
+	Rotation      string `json:"rotation,omitempty"`       // Key rotation interval, e.g. "1h"
+	Owner         string `json:"owner,omitempty"`          // Identity sending with the seed
+
+	// Group keys are shared by all members
+	Group   bool     `json:"group,omitempty"`
+	Members []string `json:"members,omitempty"` // Member pubkeys (hex) of groups we manage
+	Admin   string   `json:"admin,omitempty"`   // Pubkey (hex) that shared a received group key
 }
 
 var idCmd = &cobra.Command{
@@ -36,10 +63,12 @@ var idListCmd = &cobra.Command{
 }
 
 var idAddCmd = &cobra.Command{
-	Use:   "add <name> <nsec>",
-	Short: "Add a new identity",
-	Args:  cobra.ExactArgs(2),
-	RunE:  addIdentity,
+	Use:     "add <name> <nsec|ncryptsec>",
+	Aliases: []string{"import"},
+	Short:   "Add a new identity",
+	Long:    "Add an identity from an nsec, or from a NIP-49 ncryptsec which is decrypted with its passphrase.",
+	Args:    cobra.ExactArgs(2),
+	RunE:    addIdentity,
 }
 
 var idRemoveCmd = &cobra.Command{
@@ -68,9 +97,39 @@ var idGenerateCmd = &cobra.Command{
 var idExportCmd = &cobra.Command{
 	Use:   "export <@name|nsec>",
 	Short: "Export identity as URL with QR code",
-	Long:  "Export an identity as a URL and QR code for sharing. Accepts either @name reference or nsec directly.",
-	Args:  cobra.ExactArgs(1),
-	RunE:  exportIdentity,
+	Long: `Export an identity as a URL and QR code for sharing. Accepts either @name reference or nsec directly.
+With --ncryptsec, prints the key as a NIP-49 ncryptsec encrypted with a passphrase instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: exportIdentity,
+}
+
+var idBunkerCmd = &cobra.Command{
+	Use:   "bunker <name> <bunker://...>",
+	Short: "Add an identity backed by a NIP-46 remote signer",
+	Long: `Connect to a NIP-46 remote signer (bunker) and add its user as an identity.
+The private key stays on the signer, which signs events and does the NIP-44
+encryption and decryption for send and listen. Only the bunker URI and the
+client key the signer authorized are stored.`,
+	Args: cobra.ExactArgs(2),
+	RunE: addBunkerIdentity,
+}
+
+var idEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the identity store with a passphrase",
+	Long: `Store every identity as a NIP-49 ncryptsec encrypted with a passphrase
+instead of a plaintext nsec. Running it on an encrypted store changes the
+passphrase. Commands that need a private key then ask for the passphrase,
+or read it from NOLOC_PASSPHRASE.`,
+	Args: cobra.NoArgs,
+	RunE: encryptStore,
+}
+
+var idDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Store identities as plaintext nsecs again",
+	Args:  cobra.NoArgs,
+	RunE:  decryptStore,
 }
 
 func init() {
@@ -81,6 +140,17 @@ func init() {
 	idCmd.AddCommand(idShowCmd)
 	idCmd.AddCommand(idGenerateCmd)
 	idCmd.AddCommand(idExportCmd)
+	idCmd.AddCommand(idBunkerCmd)
+	idCmd.AddCommand(idEncryptCmd)
+	idCmd.AddCommand(idDecryptCmd)
+
+	idExportCmd.Flags().Bool("ncryptsec", false, "Export as a passphrase encrypted NIP-49 ncryptsec")
+	idShowCmd.Flags().Bool("show-seed", false, "Print the secret seed of an ephemeral seed identity")
+}
+
+// isSeed reports whether the identity is an ephemeral seed
+func (id *Identity) isSeed() bool {
+	return id.Seed != "" || id.SeedNcryptsec != ""
 }
 
 func getIdentityFile() string {
@@ -88,7 +158,22 @@ func getIdentityFile() string {
 	return filepath.Join(home, ".noloc-identities.json")
 }
 
+// loadIdentities reads the identity store and decrypts the private keys of
+// an encrypted store, asking for the passphrase
 func loadIdentities() (map[string]Identity, error) {
+	identities, err := readIdentities()
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptIdentities(identities); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// readIdentities reads the identity store without decrypting it, for
+// commands that only need names and public keys
+func readIdentities() (map[string]Identity, error) {
 	identities := make(map[string]Identity)
 
 	file := getIdentityFile()
@@ -107,7 +192,30 @@ func loadIdentities() (map[string]Identity, error) {
 	return identities, nil
 }
 
+// saveIdentities writes the identity store. In an encrypted store, new
+// identities are encrypted with the store passphrase.
 func saveIdentities(identities map[string]Identity) error {
+	stored := make(map[string]Identity, len(identities))
+	for name, id := range identities {
+		// Loaded identities carry the decrypted values next to the ncryptsecs
+		clearDecrypted(&id)
+		stored[name] = id
+	}
+
+	if encryptedStore(stored) && !fullyEncrypted(stored) {
+		passphrase, err := unlockStore(stored)
+		if err != nil {
+			return err
+		}
+		if err := encryptIdentities(stored, passphrase); err != nil {
+			return err
+		}
+	}
+
+	return writeIdentities(stored)
+}
+
+func writeIdentities(identities map[string]Identity) error {
 	data, err := json.MarshalIndent(identities, "", "  ")
 	if err != nil {
 		return err
@@ -118,7 +226,7 @@ func saveIdentities(identities map[string]Identity) error {
 }
 
 func listIdentities(cmd *cobra.Command, args []string) error {
-	identities, err := loadIdentities()
+	identities, err := readIdentities()
 	if err != nil {
 		return fmt.Errorf("failed to load identities: %w", err)
 	}
@@ -133,7 +241,25 @@ func listIdentities(cmd *cobra.Command, args []string) error {
 
 	for name, id := range identities {
 		fmt.Printf("Name: %s\n", name)
+		if id.isSeed() {
+			fmt.Printf("  Ephemeral seed, rotates every %s\n", id.Rotation)
+			if id.Owner != "" {
+				fmt.Printf("  Owner: %s\n", id.Owner)
+			}
+			fmt.Printf("  Added: %s\n", id.Added)
+			fmt.Println()
+			continue
+		}
 		fmt.Printf("  Npub: %s\n", id.Npub)
+		if id.Group {
+			fmt.Printf("  Group: %d members\n", len(id.Members))
+		}
+		if id.Bunker != "" {
+			fmt.Printf("  Remote signer: %s\n", id.Bunker)
+		}
+		if id.Contact {
+			fmt.Printf("  Contact (watch-only)\n")
+		}
 		fmt.Printf("  Added: %s\n", id.Added)
 		fmt.Println()
 	}
@@ -145,8 +271,21 @@ func addIdentity(cmd *cobra.Command, args []string) error {
 	name := args[0]
 	nsec := args[1]
 
+	// An ncryptsec is decrypted with its own passphrase, an encrypted store
+	// encrypts it again with the store passphrase
+	if strings.HasPrefix(nsec, "ncryptsec1") {
+		passphrase, err := readPassphrase("Passphrase of the ncryptsec: ", false)
+		if err != nil {
+			return err
+		}
+		nsec, err = decryptNcryptsec(nsec, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
 	if !strings.HasPrefix(nsec, "nsec1") {
-		return fmt.Errorf("invalid nsec format (must start with 'nsec1')")
+		return fmt.Errorf("invalid nsec format (must start with 'nsec1' or 'ncryptsec1')")
 	}
 
 	_, skRaw, err := nip19.Decode(nsec)
@@ -165,7 +304,7 @@ func addIdentity(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to encode npub: %w", err)
 	}
 
-	identities, err := loadIdentities()
+	identities, err := readIdentities()
 	if err != nil {
 		return fmt.Errorf("failed to load identities: %w", err)
 	}
@@ -195,7 +334,7 @@ func addIdentity(cmd *cobra.Command, args []string) error {
 func removeIdentity(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
-	identities, err := loadIdentities()
+	identities, err := readIdentities()
 	if err != nil {
 		return fmt.Errorf("failed to load identities: %w", err)
 	}
@@ -217,7 +356,7 @@ func removeIdentity(cmd *cobra.Command, args []string) error {
 func showIdentity(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
-	identities, err := loadIdentities()
+	identities, err := readIdentities()
 	if err != nil {
 		return fmt.Errorf("failed to load identities: %w", err)
 	}
@@ -228,10 +367,55 @@ func showIdentity(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Identity: %s\n", name)
-	fmt.Printf("  Nsec: %s\n", id.Nsec)
+	if id.isSeed() {
+		if showSeed, _ := cmd.Flags().GetBool("show-seed"); showSeed {
+			if id.Seed == "" {
+				decrypted, err := loadIdentities()
+				if err != nil {
+					return fmt.Errorf("failed to load identities: %w", err)
+				}
+				id = decrypted[name]
+			}
+			fmt.Printf("  Ephemeral seed: %s\n", id.Seed)
+		} else {
+			fmt.Printf("  Ephemeral seed: hidden, show it with --show-seed\n")
+		}
+		fmt.Printf("  Rotation: %s\n", id.Rotation)
+		if id.Owner != "" {
+			fmt.Printf("  Owner: %s\n", id.Owner)
+		}
+		fmt.Printf("  Added: %s\n", id.Added)
+		return nil
+	}
+	if id.Contact {
+		fmt.Printf("  Contact (watch-only)\n")
+	} else if id.Bunker != "" {
+		fmt.Printf("  Remote signer: %s\n", id.Bunker)
+	} else if id.Ncryptsec != "" {
+		fmt.Printf("  Ncryptsec: %s\n", id.Ncryptsec)
+	} else {
+		fmt.Printf("  Nsec: %s\n", id.Nsec)
+	}
 	fmt.Printf("  Npub: %s\n", id.Npub)
 	fmt.Printf("  Hex:  %s\n", id.Hex)
 	fmt.Printf("  Added: %s\n", id.Added)
+	if id.Note != "" {
+		fmt.Printf("  Note: %s\n", id.Note)
+	}
+	if len(id.Relays) > 0 {
+		fmt.Printf("  Relays: %s\n", strings.Join(id.Relays, ", "))
+	}
+	if id.Group {
+		fmt.Printf("  Group members:\n")
+		for _, member := range id.Members {
+			npub, _ := nip19.EncodePublicKey(member)
+			fmt.Printf("    - %s\n", npub)
+		}
+		if id.Admin != "" {
+			npub, _ := nip19.EncodePublicKey(id.Admin)
+			fmt.Printf("  Shared by: %s\n", npub)
+		}
+	}
 
 	return nil
 }
@@ -249,8 +433,12 @@ func ResolveIdentityReference(value string, keyType string) (string, error) {
 		return "", fmt.Errorf("invalid identity reference: missing name after @")
 	}
 
-	// Load identities
-	identities, err := loadIdentities()
+	// Load identities, only private keys need the store to be decrypted
+	load := readIdentities
+	if keyType == "nsec" {
+		load = loadIdentities
+	}
+	identities, err := load()
 	if err != nil {
 		return "", fmt.Errorf("failed to load identities: %w", err)
 	}
@@ -264,6 +452,12 @@ func ResolveIdentityReference(value string, keyType string) (string, error) {
 	// Return the appropriate key based on keyType
 	switch keyType {
 	case "nsec":
+		if identity.Contact {
+			return "", fmt.Errorf("'%s' is a watch-only contact and has no nsec", name)
+		}
+		if identity.Bunker != "" {
+			return "", fmt.Errorf("identity '%s' is held by a remote signer and has no nsec", name)
+		}
 		return identity.Nsec, nil
 	case "npub":
 		return identity.Npub, nil
@@ -272,11 +466,114 @@ func ResolveIdentityReference(value string, keyType string) (string, error) {
 	}
 }
 
+// resolvePubkey resolves @name, npub, nprofile, NIP-05 user@domain or hex
+// public key to a hex public key
+func resolvePubkey(value string) (string, error) {
+	pubkey, _, err := resolveProfile(value)
+	return pubkey, err
+}
+
+// resolveProfile resolves a public key reference like resolvePubkey and
+// also returns its relay hints: the relays of an nprofile, of a NIP-05
+// response or the preferred relays of a contact
+func resolveProfile(value string) (string, []string, error) {
+	if strings.HasPrefix(value, "@") {
+		name := strings.TrimPrefix(value, "@")
+		identities, err := readIdentities()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to load identities: %w", err)
+		}
+		if identity, exists := identities[name]; exists {
+			if identity.Hex == "" {
+				return "", nil, fmt.Errorf("'%s' has no public key", name)
+			}
+			return identity.Hex, identity.Relays, nil
+		}
+	}
+
+	if isNIP05(value) {
+		return queryNIP05(context.Background(), value)
+	}
+
+	resolved, err := ResolveIdentityReference(value, "npub")
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(resolved, "npub1"):
+		_, pubkeyRaw, err := nip19.Decode(resolved)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to decode npub: %w", err)
+		}
+		resolved = pubkeyRaw.(string)
+
+	case strings.HasPrefix(resolved, "nprofile1"):
+		_, profileRaw, err := nip19.Decode(resolved)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to decode nprofile: %w", err)
+		}
+		profile := profileRaw.(nostr.ProfilePointer)
+		return profile.PublicKey, profile.Relays, nil
+	}
+
+	if !nostr.IsValid32ByteHex(resolved) {
+		return "", nil, fmt.Errorf("invalid public key %q: must be npub, nprofile, hex, user@domain or @identity reference", value)
+	}
+
+	return strings.ToLower(resolved), nil, nil
+}
+
+func addBunkerIdentity(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	uri := args[1]
+
+	identities, err := readIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+
+	if _, exists := identities[name]; exists {
+		return fmt.Errorf("identity '%s' already exists", name)
+	}
+
+	// The signer authorizes this client key, which is reused for later requests
+	clientSK := nostr.GeneratePrivateKey()
+	fmt.Println("Connecting to remote signer...")
+	signer, err := connectBunker(context.Background(), clientSK, uri)
+	if err != nil {
+		return err
+	}
+
+	npub, err := nip19.EncodePublicKey(signer.pubkey)
+	if err != nil {
+		return fmt.Errorf("failed to encode npub: %w", err)
+	}
+
+	identities[name] = Identity{
+		Name:         name,
+		Npub:         npub,
+		Hex:          signer.pubkey,
+		Added:        time.Now().Format("2006-01-02 15:04:05"),
+		Bunker:       stripBunkerSecret(uri),
+		BunkerClient: clientSK,
+	}
+
+	if err := saveIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save identities: %w", err)
+	}
+
+	fmt.Printf("Added remote signer identity '%s'\n", name)
+	fmt.Printf("  Npub: %s\n", npub)
+
+	return nil
+}
+
 func generateIdentity(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
 	// Load existing identities
-	identities, err := loadIdentities()
+	identities, err := readIdentities()
 	if err != nil {
 		return fmt.Errorf("failed to load identities: %w", err)
 	}
@@ -319,6 +616,7 @@ func exportIdentity(cmd *cobra.Command, args []string) error {
 	input := args[0]
 	var nsec string
 	var name string
+	var ncryptsec string
 
 	// Check if input is an identity reference or nsec
 	if strings.HasPrefix(input, "@") {
@@ -333,16 +631,28 @@ func exportIdentity(cmd *cobra.Command, args []string) error {
 		if !exists {
 			return fmt.Errorf("identity '%s' not found", name)
 		}
+		switch {
+		case identity.Contact:
+			return fmt.Errorf("'%s' is a watch-only contact and has no private key to export", name)
+		case identity.isSeed():
+			return fmt.Errorf("'%s' is an ephemeral seed and has no private key to export (see 'noloc id show --show-seed')", name)
+		case identity.Bunker != "":
+			return fmt.Errorf("identity '%s' is held by a remote signer and has no private key to export", name)
+		}
 		nsec = identity.Nsec
+		ncryptsec = identity.Ncryptsec
 	} else if strings.HasPrefix(input, "nsec1") {
 		// It's a direct nsec
 		nsec = input
 		// Try to find the name for this nsec
-		identities, _ := loadIdentities()
-		for idName, identity := range identities {
-			if identity.Nsec == nsec {
-				name = idName
-				break
+		identities, _ := readIdentities()
+		if _, skRaw, err := nip19.Decode(nsec); err == nil {
+			pubkey, _ := nostr.GetPublicKey(skRaw.(string))
+			for idName, identity := range identities {
+				if identity.Hex == pubkey {
+					name = idName
+					break
+				}
 			}
 		}
 		// If no name found, use "unknown"
@@ -360,6 +670,10 @@ func exportIdentity(cmd *cobra.Command, args []string) error {
 	}
 	sk := skRaw.(string)
 
+	if asNcryptsec, _ := cmd.Flags().GetBool("ncryptsec"); asNcryptsec {
+		return exportNcryptsec(nsec, ncryptsec)
+	}
+
 	// Build the URL
 	params := url.Values{}
 	params.Add("g", sk)
@@ -380,3 +694,74 @@ func exportIdentity(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// exportNcryptsec prints the key as a NIP-49 ncryptsec. Keys of an encrypted
+// store are exported with the store passphrase, others ask for a new one.
+func exportNcryptsec(nsec, ncryptsec string) error {
+	if ncryptsec == "" {
+		passphrase, err := readPassphrase("Passphrase for the ncryptsec: ", true)
+		if err != nil {
+			return err
+		}
+		ncryptsec, err = encryptNsec(nsec, passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt nsec: %w", err)
+		}
+	}
+
+	qr, err := qrcode.New(ncryptsec, qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("failed to generate QR code: %w", err)
+	}
+
+	fmt.Println("Ncryptsec:")
+	fmt.Println(ncryptsec)
+	fmt.Println("\nQR Code:")
+	fmt.Println(qr.ToSmallString(false))
+
+	return nil
+}
+
+func encryptStore(cmd *cobra.Command, args []string) error {
+	identities, err := loadIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+	if len(identities) == 0 {
+		return fmt.Errorf("no identities to encrypt")
+	}
+
+	passphrase, err := readPassphrase("New identity store passphrase: ", true)
+	if err != nil {
+		return err
+	}
+
+	// Encrypt every key again when the passphrase changes
+	clearEncrypted(identities)
+	if err := encryptIdentities(identities, passphrase); err != nil {
+		return err
+	}
+	if err := writeIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save identities: %w", err)
+	}
+	storePassphrase = passphrase
+
+	fmt.Printf("Encrypted %d identities as NIP-49 ncryptsec\n", len(identities))
+	return nil
+}
+
+func decryptStore(cmd *cobra.Command, args []string) error {
+	identities, err := loadIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+
+	clearEncrypted(identities)
+	if err := writeIdentities(identities); err != nil {
+		return fmt.Errorf("failed to save identities: %w", err)
+	}
+
+	fmt.Printf("Stored %d identities as plaintext nsec\n", len(identities))
+	fmt.Println("\n⚠️  Private keys are no longer protected by a passphrase!")
+	return nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportKeylessIdentity(t *testing.T) {
+	testConfig(t, "")
+	contact := testIdentity("bob")
+	contact.Nsec = ""
+	contact.Contact = true
+	signer := testIdentity("phone")
+	signer.Nsec = ""
+	signer.Bunker = "bunker://" + strings.Repeat("b", 64) + "?relay=wss://relay.example.com"
+	signer.BunkerClient = strings.Repeat("c", 64)
+	if err := saveIdentities(map[string]Identity{
+		"bob":     contact,
+		"courier": {Name: "courier", Seed: testSeed, Rotation: "1h0m0s"},
+		"phone":   signer,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"bob", "watch-only contact"},
+		{"courier", "ephemeral seed"},
+		{"phone", "remote signer"},
+	}
+	for _, ncryptsec := range []string{"false", "true"} {
+		idExportCmd.Flags().Set("ncryptsec", ncryptsec)
+		for _, tt := range tests {
+			err := exportIdentity(idExportCmd, []string{"@" + tt.name})
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), "no private key") {
+				t.Errorf("export @%s (ncryptsec %s): %v, want %s without private key", tt.name, ncryptsec, err, tt.want)
+			}
+		}
+	}
+	idExportCmd.Flags().Set("ncryptsec", "false")
+}
@@ -8,14 +8,12 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
-	"github.com/mmcloughlin/geohash"
 	"github.com/nbd-wtf/go-nostr"
-	"github.com/nbd-wtf/go-nostr/nip19"
-	"github.com/nbd-wtf/go-nostr/nip44"
 	"github.com/spf13/cobra"
+
+	"noloc/location"
 )
 
 type ISSPosition struct {
@@ -37,145 +35,96 @@ var issCmd = &cobra.Command{
 	Use:   "iss",
 	Short: "Track ISS location and broadcast via Nostr",
 	Long: `Demo command that fetches the International Space Station's current location
-and broadcasts it as encrypted Nostr events using NIP-44 encryption.`,
+and broadcasts it as encrypted Nostr events using NIP-44 encryption. It is
+'noloc share iss' to --receiver, with events that expire after two intervals.
+
+With --ephemeral @seed each update is signed with the key derived from the
+seed for the current rotation epoch, or with --per-session with the key of
+the epoch the tracker started in, instead of --sender.
+
+Privacy policies and private zones of ~/.noloc.yaml apply as in send.
+--dry-run logs the location each update would publish instead.`,
 	RunE: runISS,
 }
 
 func init() {
 	rootCmd.AddCommand(issCmd)
 	issCmd.Flags().IntP("interval", "i", defaultInterval, "Update interval in seconds")
-	issCmd.Flags().StringP("sender", "s", "", "Sender private key (nsec... or @identity)")
-	issCmd.Flags().StringP("receiver", "r", "", "Receiver public key (npub... or @identity)")
+	issCmd.Flags().StringP("sender", "s", "", "Sender private key (nsec..., bunker:// URI or @identity), required unless --ephemeral")
+	issCmd.Flags().String("ephemeral", "", "Sign with keys derived from this seed (@name) instead of --sender")
+	issCmd.Flags().Bool("per-session", false, "With --ephemeral, keep one key for the whole session instead of rotating")
+	issCmd.Flags().StringP("receiver", "r", "", "Receiver public key (npub..., nprofile..., user@domain or @identity)")
 	issCmd.Flags().Bool("anon", false, "Send anonymous location (no p-tag)")
+	issCmd.Flags().Bool("wrap", false, "Hide the sender in NIP-59 gift wraps (kind 1059)")
+	issCmd.Flags().Bool("dry-run", false, "Log the location of each update without publishing")
 	issCmd.Flags().Int("accuracy", 0, "Location accuracy in meters (adds 'accuracy' tag to encrypted content)")
 	issCmd.Flags().Int("precision", 0, "Geohash precision (number of characters, 1-12)")
 
-	issCmd.MarkFlagRequired("sender")
 	issCmd.MarkFlagRequired("receiver")
 }
 
 func runISS(cmd *cobra.Command, args []string) error {
 	LoadFlags(cmd)
 
-	// Validate configuration
-	config, err := validateISSConfig()
+	config, err := loadShareConfig(false)
 	if err != nil {
 		return err
 	}
+	config.ttl = 2 * updateInterval()
 
 	log.Printf("Starting ISS location tracker...")
-	if config.anon {
-		log.Printf("Mode: Anonymous (no p-tag)")
-	} else {
-		log.Printf("Mode: Direct message")
-	}
-	log.Printf("Update interval: %d seconds", config.interval)
-	log.Printf("Relay: %s", config.relayURL)
+	config.logMode()
+	log.Printf("Update interval: %s", updateInterval())
 
-	// Main tracking loop
-	for {
-		processISSUpdate(config)
-		time.Sleep(time.Duration(config.interval) * time.Second)
-	}
+	return share(newISS(false), config)
 }
 
-type issConfig struct {
-	senderSK       string
-	receiverPubkey string
-	relayURL       string
-	interval       int
-	anon           bool
-	accuracy_m     int
-	precision      int
+// issSource polls the position of the ISS every interval
+type issSource struct {
+	apiURL   string
+	interval time.Duration
+	details  location.Location
+	next     time.Time
 }
 
-func validateISSConfig() (*issConfig, error) {
-	sender := k.String("sender")
-	if sender == "" {
-		return nil, fmt.Errorf("sender is required (--sender or -s)")
-	}
-
-	receiver := k.String("receiver")
-	if receiver == "" {
-		return nil, fmt.Errorf("receiver is required (--receiver or -r)")
-	}
-
-	relayURL := k.String("relay")
-	if relayURL == "" {
-		return nil, fmt.Errorf("relay URL is required (--relay)")
-	}
-
-	// Validate sender format (should be nsec after resolution)
-	if !strings.HasPrefix(sender, "nsec1") {
-		return nil, fmt.Errorf("sender must be an nsec private key (starting with 'nsec1') or @identity reference")
-	}
-
-	// Validate receiver format (should be npub after resolution)
-	if !strings.HasPrefix(receiver, "npub1") {
-		return nil, fmt.Errorf("receiver must be an npub public key (starting with 'npub1') or @identity reference")
-	}
-
-	_, senderSK, err := nip19.Decode(sender)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode sender nsec: %w", err)
-	}
-
-	_, receiverPubkeyRaw, err := nip19.Decode(receiver)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode receiver npub: %w", err)
-	}
+func newISSSource() (PositionSource, error) {
+	return newISS(k.Bool("public")), nil
+}
 
-	interval := k.Int("interval")
-	if interval == 0 {
-		interval = k.Int("update.interval")
-		if interval == 0 {
-			interval = defaultInterval
+// newISS creates the ISS source with the tags iss and iss-public have
+// always published: a title and summary in public events, a name tag in
+// encrypted ones
+func newISS(public bool) *issSource {
+	details := location.Location{Extra: nostr.Tags{{"name", "ISS"}}}
+	if public {
+		details = location.Location{
+			Title:   "ISS",
+			Summary: "International Space Station current position",
 		}
 	}
+	return &issSource{apiURL: issAPIURL, interval: updateInterval(), details: details}
+}
 
-	anon := k.Bool("anon")
-	accuracy_m := k.Int("accuracy")
-	precision := k.Int("precision")
-
-	// Validate precision if provided
-	if precision != 0 && (precision < 1 || precision > 12) {
-		return nil, fmt.Errorf("precision must be between 1 and 12 characters")
+func (s *issSource) Next(ctx context.Context) (*Position, error) {
+	if err := sleepContext(ctx, time.Until(s.next)); err != nil {
+		return nil, err
 	}
+	s.next = time.Now().Add(s.interval)
 
-	return &issConfig{
-		senderSK:       senderSK.(string),
-		receiverPubkey: receiverPubkeyRaw.(string),
-		relayURL:       relayURL,
-		interval:       interval,
-		anon:           anon,
-		accuracy_m:     accuracy_m,
-		precision:      precision,
-	}, nil
-}
-
-func processISSUpdate(config *issConfig) {
-	position, err := fetchISSLocation(issAPIURL)
+	position, err := fetchISSLocation(s.apiURL)
 	if err != nil {
-		log.Printf("Error fetching ISS location: %v", err)
-		return
+		return nil, err
 	}
-
-	log.Printf("ISS Position: Lat=%s, Lon=%s",
-		position.ISSPosition.Latitude,
-		position.ISSPosition.Longitude)
-
-	ttl := 2 * config.interval
-	event, err := createLocationEvent(config.senderSK, config.receiverPubkey, position, ttl, config.anon, config.accuracy_m, config.precision)
+	lat, lon, err := position.coordinates()
 	if err != nil {
-		log.Printf("Error creating location event: %v", err)
-		return
+		return nil, err
 	}
 
-	if err := publishToRelay(config.relayURL, event); err != nil {
-		log.Printf("Error publishing to relay: %v", err)
-	} else {
-		log.Printf("Successfully published location event (ID: %s)", event.ID)
+	p := &Position{Track: issLocationID, Lat: lat, Lon: lon, Details: s.details}
+	if position.Timestamp > 0 {
+		p.Time = time.Unix(position.Timestamp, 0)
 	}
+	return p, nil
 }
 
 func fetchISSLocation(apiURL string) (*ISSPosition, error) {
@@ -198,113 +147,17 @@ func fetchISSLocation(apiURL string) (*ISSPosition, error) {
 	return &position, nil
 }
 
-func createLocationEvent(senderSK, receiverPubkey string, position *ISSPosition, ttl int, anon bool, accuracy_m int, precision int) (*nostr.Event, error) {
-	// Parse coordinates
-	lat, err := strconv.ParseFloat(position.ISSPosition.Latitude, 64)
+// coordinates parses the string coordinates of the API response
+func (p *ISSPosition) coordinates() (float64, float64, error) {
+	lat, err := strconv.ParseFloat(p.ISSPosition.Latitude, 64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse latitude: %w", err)
+		return 0, 0, fmt.Errorf("failed to parse latitude: %w", err)
 	}
 
-	lon, err := strconv.ParseFloat(position.ISSPosition.Longitude, 64)
+	lon, err := strconv.ParseFloat(p.ISSPosition.Longitude, 64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse longitude: %w", err)
-	}
-
-	// Generate geohash with specified precision or default
-	var gh string
-	if precision > 0 {
-		gh = geohash.EncodeWithPrecision(lat, lon, uint(precision))
-	} else {
-		gh = geohash.Encode(lat, lon)
-	}
-
-	// Create location data
-	locationData := [][]interface{}{
-		{"g", gh},
-		{"name", "ISS"},
-	}
-
-	// Add accuracy tag if specified
-	if accuracy_m > 0 {
-		locationData = append(locationData, []interface{}{"accuracy", strconv.Itoa(accuracy_m)})
-	}
-
-	// Encrypt location data
-	encryptedContent, err := encryptLocationData(locationData, senderSK, receiverPubkey)
-	if err != nil {
-		return nil, err
-	}
-
-	// Build event
-	return buildLocationEvent(senderSK, receiverPubkey, encryptedContent, ttl, anon)
-}
-
-func encryptLocationData(locationData [][]interface{}, senderSK, receiverPubkey string) (string, error) {
-	locationJSON, err := json.Marshal(locationData)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal location data: %w", err)
-	}
-
-	conversationKey, err := nip44.GenerateConversationKey(receiverPubkey, senderSK)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate conversation key: %w", err)
-	}
-
-	encryptedContent, err := nip44.Encrypt(string(locationJSON), conversationKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to encrypt content: %w", err)
-	}
-
-	return encryptedContent, nil
-}
-
-func buildLocationEvent(senderSK, receiverPubkey, encryptedContent string, ttl int, anon bool) (*nostr.Event, error) {
-	senderPubkey, err := nostr.GetPublicKey(senderSK)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get sender public key: %w", err)
-	}
-
-	expiration := time.Now().Add(time.Duration(ttl) * time.Second).Unix()
-
-	// Build tags based on anon flag
-	tags := nostr.Tags{
-		{"d", issLocationID},
-		{"expiration", fmt.Sprintf("%d", expiration)},
-	}
-
-	// Only add p-tag if not anonymous
-	if !anon {
-		tags = append(nostr.Tags{{"p", receiverPubkey}}, tags...)
-	}
-
-	event := &nostr.Event{
-		PubKey:    senderPubkey,
-		CreatedAt: nostr.Timestamp(time.Now().Unix()),
-		Kind:      30473,
-		Tags:      tags,
-		Content:   encryptedContent,
-	}
-
-	if err := event.Sign(senderSK); err != nil {
-		return nil, fmt.Errorf("failed to sign event: %w", err)
-	}
-
-	return event, nil
-}
-
-func publishToRelay(relayURL string, event *nostr.Event) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	relay, err := nostr.RelayConnect(ctx, relayURL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to relay: %w", err)
-	}
-	defer relay.Close()
-
-	if err := relay.Publish(ctx, *event); err != nil {
-		return fmt.Errorf("failed to publish event: %w", err)
+		return 0, 0, fmt.Errorf("failed to parse longitude: %w", err)
 	}
 
-	return nil
+	return lat, lon, nil
 }
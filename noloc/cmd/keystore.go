@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip49"
+)
+
+// ncryptsecLogN is the NIP-49 scrypt cost, 2^16 rounds take about 100 ms
+const ncryptsecLogN = 16
+
+// storePassphrase caches the identity store passphrase for the process
+var storePassphrase string
+
+// secret is a private value of an identity and its NIP-49 encrypted form,
+// which an encrypted store keeps instead
+type secret struct {
+	name      string
+	plain     *string
+	encrypted *string
+	nsec      bool // plain is a bech32 nsec, else a hex key
+}
+
+// secrets returns the private values of an identity
+func (id *Identity) secrets() []secret {
+	return []secret{
+		{"nsec", &id.Nsec, &id.Ncryptsec, true},
+		{"bunker client key", &id.BunkerClient, &id.BunkerClientNcryptsec, false},
+		{"seed", &id.Seed, &id.SeedNcryptsec, false},
+	}
+}
+
+// encryptedStore reports whether the identity file holds ncryptsecs
+func encryptedStore(identities map[string]Identity) bool {
+	for _, id := range identities {
+		for _, s := range id.secrets() {
+			if *s.encrypted != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fullyEncrypted reports whether every private value already has an
+// ncryptsec
+func fullyEncrypted(identities map[string]Identity) bool {
+	for _, id := range identities {
+		for _, s := range id.secrets() {
+			if *s.encrypted == "" && *s.plain != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// unlockStore returns the passphrase of an encrypted identity store from
+// NOLOC_PASSPHRASE or a prompt, checked against one of its ncryptsecs
+func unlockStore(identities map[string]Identity) (string, error) {
+	if storePassphrase != "" {
+		return storePassphrase, nil
+	}
+
+	var check string
+	for _, id := range identities {
+		for _, s := range id.secrets() {
+			if *s.encrypted != "" {
+				check = *s.encrypted
+			}
+		}
+	}
+
+	passphrase, err := readPassphrase("Identity store passphrase: ", false)
+	if err != nil {
+		return "", err
+	}
+	if check != "" {
+		if _, err := nip49.Decrypt(check, passphrase); err != nil {
+			return "", fmt.Errorf("wrong identity store passphrase")
+		}
+	}
+
+	storePassphrase = passphrase
+	return passphrase, nil
+}
+
+// readPassphrase returns the passphrase from NOLOC_PASSPHRASE (or
+// passphrase in the config) or prompts for it, twice when confirming a new
+// one
+func readPassphrase(prompt string, confirm bool) (string, error) {
+	if passphrase := k.String("passphrase"); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := readPassword()
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is required (prompt or NOLOC_PASSPHRASE)")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		repeated, err := readPassword()
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if repeated != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+// decryptIdentities fills in every private value stored as an ncryptsec
+func decryptIdentities(identities map[string]Identity) error {
+	if !encryptedStore(identities) {
+		return nil
+	}
+
+	passphrase, err := unlockStore(identities)
+	if err != nil {
+		return err
+	}
+
+	for name, id := range identities {
+		for _, s := range id.secrets() {
+			if *s.encrypted == "" || *s.plain != "" {
+				continue
+			}
+			plain, err := decryptSecret(s, passphrase)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s of identity '%s': %w", s.name, name, err)
+			}
+			*s.plain = plain
+		}
+		identities[name] = id
+	}
+	return nil
+}
+
+// encryptIdentities replaces every private value with an ncryptsec,
+// encrypting those that have none yet
+func encryptIdentities(identities map[string]Identity, passphrase string) error {
+	for name, id := range identities {
+		for _, s := range id.secrets() {
+			if *s.plain == "" {
+				continue
+			}
+			if *s.encrypted == "" {
+				encrypted, err := encryptSecret(s, passphrase)
+				if err != nil {
+					return fmt.Errorf("failed to encrypt %s of identity '%s': %w", s.name, name, err)
+				}
+				*s.encrypted = encrypted
+			}
+			*s.plain = ""
+		}
+		identities[name] = id
+	}
+	return nil
+}
+
+// clearEncrypted drops the ncryptsecs of decrypted identities, before they
+// are stored as plaintext or encrypted with a new passphrase
+func clearEncrypted(identities map[string]Identity) {
+	for name, id := range identities {
+		for _, s := range id.secrets() {
+			*s.encrypted = ""
+		}
+		identities[name] = id
+	}
+}
+
+// clearDecrypted drops the private values that also have an ncryptsec, so
+// that loaded identities are stored encrypted only
+func clearDecrypted(id *Identity) {
+	for _, s := range id.secrets() {
+		if *s.encrypted != "" {
+			*s.plain = ""
+		}
+	}
+}
+
+func encryptSecret(s secret, passphrase string) (string, error) {
+	if s.nsec {
+		return encryptNsec(*s.plain, passphrase)
+	}
+	return nip49.Encrypt(*s.plain, passphrase, ncryptsecLogN, nip49.ClientDoesNotTrackThisData)
+}
+
+func decryptSecret(s secret, passphrase string) (string, error) {
+	if s.nsec {
+		return decryptNcryptsec(*s.encrypted, passphrase)
+	}
+	sk, err := nip49.Decrypt(*s.encrypted, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt ncryptsec: %w", err)
+	}
+	return sk, nil
+}
+
+func encryptNsec(nsec, passphrase string) (string, error) {
+	_, skRaw, err := nip19.Decode(nsec)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode nsec: %w", err)
+	}
+	return nip49.Encrypt(skRaw.(string), passphrase, ncryptsecLogN, nip49.ClientDoesNotTrackThisData)
+}
+
+func decryptNcryptsec(ncryptsec, passphrase string) (string, error) {
+	sk, err := nip49.Decrypt(ncryptsec, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt ncryptsec: %w", err)
+	}
+	return nip19.EncodePrivateKey(sk)
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPassphrase = "correct horse"
+
+// writeEncryptedStore stores the identities encrypted with testPassphrase
+func writeEncryptedStore(t *testing.T, identities map[string]Identity) {
+	t.Helper()
+	stored := make(map[string]Identity, len(identities))
+	for name, id := range identities {
+		stored[name] = id
+	}
+	if err := encryptIdentities(stored, testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeIdentities(stored); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveIdentitiesEncryptedRoundTrip(t *testing.T) {
+	testConfig(t, "")
+	k.Set("passphrase", testPassphrase)
+
+	alice, bob, carol := testIdentity("alice"), testIdentity("bob"), testIdentity("carol")
+	writeEncryptedStore(t, map[string]Identity{"alice": alice, "bob": bob})
+
+	identities, err := loadIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identities["alice"].Nsec != alice.Nsec || identities["bob"].Nsec != bob.Nsec {
+		t.Fatal("loaded identities were not decrypted")
+	}
+
+	identities["carol"] = carol
+	if err := saveIdentities(identities); err != nil {
+		t.Fatal(err)
+	}
+	if file := readStoreFile(t); strings.Contains(file, "nsec1") {
+		t.Fatalf("identity file contains a plaintext nsec:\n%s", file)
+	}
+
+	stored, err := readIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, id := range stored {
+		if id.Nsec != "" || id.Ncryptsec == "" {
+			t.Errorf("%s: nsec %q, ncryptsec %q", name, id.Nsec, id.Ncryptsec)
+		}
+	}
+
+	storePassphrase = ""
+	reloaded, err := loadIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []Identity{alice, bob, carol} {
+		if got := reloaded[want.Name].Nsec; got != want.Nsec {
+			t.Errorf("%s: nsec %s after round trip, want %s", want.Name, got, want.Nsec)
+		}
+	}
+}
+
+func TestSaveIdentitiesPlaintext(t *testing.T) {
+	testConfig(t, "")
+
+	alice := testIdentity("alice")
+	if err := saveIdentities(map[string]Identity{"alice": alice}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := readIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored["alice"].Nsec != alice.Nsec || stored["alice"].Ncryptsec != "" {
+		t.Errorf("plaintext store changed: %+v", stored["alice"])
+	}
+}
+
+func TestUnlockStoreWrongPassphrase(t *testing.T) {
+	testConfig(t, "")
+	writeEncryptedStore(t, map[string]Identity{"alice": testIdentity("alice")})
+	k.Set("passphrase", "wrong")
+
+	if _, err := loadIdentities(); err == nil || !strings.Contains(err.Error(), "wrong identity store passphrase") {
+		t.Errorf("err = %v, want wrong passphrase", err)
+	}
+}
+
+func TestPassphraseFromEnvironment(t *testing.T) {
+	testConfig(t, "")
+	t.Setenv("NOLOC_PASSPHRASE", testPassphrase)
+	initConfig()
+
+	passphrase, err := readPassphrase("Passphrase: ", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passphrase != testPassphrase {
+		t.Errorf("passphrase = %q, want %q", passphrase, testPassphrase)
+	}
+}
+
+func TestReadPassphraseFromPipe(t *testing.T) {
+	// Each prompt reads one line, the next prompt gets the rest
+	testConfig(t, "first\r\nfirst\nsecond\nthird\n")
+
+	passphrase, err := readPassphrase("Passphrase: ", true)
+	if err != nil || passphrase != "first" {
+		t.Fatalf("confirmed passphrase = %q, %v", passphrase, err)
+	}
+	if passphrase, err = readPassphrase("Passphrase: ", true); err == nil || !strings.Contains(err.Error(), "do not match") {
+		t.Errorf("mismatch: %q, %v", passphrase, err)
+	}
+	if _, err = readPassphrase("Passphrase: ", false); err == nil {
+		t.Error("want an error when the input is used up")
+	}
+}
+
+func TestEnvKey(t *testing.T) {
+	tests := map[string]string{
+		"NOLOC_PASSPHRASE":      "passphrase",
+		"NOLOC_RELAY":           "relay",
+		"NOLOC_UPDATE_INTERVAL": "update.interval",
+		"NOLOC_GPSD_ADDRESS":    "gpsd.address",
+	}
+	for name, want := range tests {
+		if got := envKey(name); got != want {
+			t.Errorf("envKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"noloc/location"
+)
+
+type publicListenConfig struct {
+	relayURL      string
+	authors       []string
+	identifiers   []string
+	hashtags      []string
+	geohashPrefix string
+	geo           *geoFilter
+	format        string
+	authorNames   *authorIndex // Names of known authors
+}
+
+func validatePublicListenConfig() (*publicListenConfig, error) {
+	relayURL, err := primaryRelayURL()
+	if err != nil {
+		return nil, err
+	}
+
+	var authors []string
+	for _, author := range k.Strings("author") {
+		pubkey, err := resolvePubkey(author)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve author: %w", err)
+		}
+		authors = append(authors, pubkey)
+	}
+
+	geohashPrefix := strings.ToLower(k.String("geohash"))
+	if geohashPrefix != "" && !location.ValidGeohash(geohashPrefix) {
+		return nil, fmt.Errorf("invalid geohash prefix: %s", geohashPrefix)
+	}
+
+	geo, err := parseGeoFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := parseOutputFormat()
+	if err != nil {
+		return nil, err
+	}
+
+	return &publicListenConfig{
+		relayURL:      relayURL,
+		authors:       authors,
+		identifiers:   k.Strings("identifier"),
+		hashtags:      k.Strings("hashtag"),
+		geohashPrefix: geohashPrefix,
+		geo:           geo,
+		format:        format,
+		authorNames:   newAuthorIndex(),
+	}, nil
+}
+
+// filter builds the relay filter. Relays match #g values exactly, which
+// finds events at any precision through their geohash prefix g tags. An area
+// filter subscribes to its geohash cover set, narrowed to the cells inside
+// the geohash prefix when both are set.
+func (c *publicListenConfig) filter() nostr.Filter {
+	filter := nostr.Filter{
+		Kinds:   []int{location.KindPublic},
+		Authors: c.authors,
+		Tags:    nostr.TagMap{},
+	}
+	if len(c.identifiers) > 0 {
+		filter.Tags["d"] = c.identifiers
+	}
+	if len(c.hashtags) > 0 {
+		filter.Tags["t"] = c.hashtags
+	}
+	if g := c.geohashes(); len(g) > 0 {
+		filter.Tags["g"] = g
+	}
+	return filter
+}
+
+// geohashes returns the #g values of the geohash prefix and the area cover
+// set. When they do not overlap, the prefix alone is subscribed and matches
+// drops the events.
+func (c *publicListenConfig) geohashes() []string {
+	if c.geo == nil {
+		if c.geohashPrefix == "" {
+			return nil
+		}
+		return []string{c.geohashPrefix}
+	}
+
+	cells := c.geo.prefixes()
+	if c.geohashPrefix == "" {
+		return cells
+	}
+	var inside []string
+	for _, cell := range cells {
+		switch {
+		case strings.HasPrefix(cell, c.geohashPrefix):
+			inside = append(inside, cell)
+		case strings.HasPrefix(c.geohashPrefix, cell):
+			// The prefix lies inside this cell and is narrower
+			return []string{c.geohashPrefix}
+		}
+	}
+	if len(inside) == 0 {
+		return []string{c.geohashPrefix}
+	}
+	return inside
+}
+
+// matches reports whether the event passes the geohash prefix and area
+// filters
+func (c *publicListenConfig) matches(event *nostr.Event) bool {
+	gh := locationGeohash(event.Tags)
+	if c.geohashPrefix != "" && !strings.HasPrefix(gh, c.geohashPrefix) {
+		return false
+	}
+	if c.geo != nil && !c.geo.contains(gh) {
+		return false
+	}
+	return true
+}
+
+func runPublicListen() error {
+	config, err := validatePublicListenConfig()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting public location listener...")
+	log.Printf("Relay: %s", config.relayURL)
+	if len(config.authors) > 0 {
+		log.Printf("Authors: %d", len(config.authors))
+	}
+	if len(config.identifiers) > 0 {
+		log.Printf("D-tags: %s", strings.Join(config.identifiers, ", "))
+	}
+	if len(config.hashtags) > 0 {
+		log.Printf("Hashtags: %s", strings.Join(config.hashtags, ", "))
+	}
+	if config.geohashPrefix != "" {
+		log.Printf("Geohash prefix: %s", config.geohashPrefix)
+	}
+	if config.geo != nil {
+		log.Printf("Area: %s (%d geohash cells)", config.geo, len(config.geo.prefixes()))
+	}
+	log.Println("Listening for public location messages...")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		log.Println("Shutting down...")
+		cancel()
+	}()
+
+	if config.format == outputText {
+		fmt.Println("=============================================================")
+	}
+
+	return subscribeWithReconnect(ctx, config.relayURL, []nostr.Filter{config.filter()}, func(event *nostr.Event) {
+		if !config.matches(event) {
+			return
+		}
+		if config.format != outputText {
+			out := publicEventOutput(event)
+			out.AuthorName = config.authorNames.name(event)
+			writeEventOutput(config.format, out)
+			return
+		}
+		outputPublicFormatted(event, config.authorNames)
+	})
+}
+
+func outputPublicFormatted(event *nostr.Event, authors *authorIndex) {
+	fmt.Printf("\n📍 New Public Location Event Received\n")
+	fmt.Printf("Event ID: %s\n", event.ID)
+	fmt.Printf("From: %s\n", authors.label(event))
+	fmt.Printf("Created: %s\n", event.CreatedAt.Time().Format("2006-01-02 15:04:05"))
+
+	fmt.Printf("\nTags:\n")
+	for _, tag := range event.Tags {
+		if len(tag) > 0 {
+			fmt.Printf("  - %s", tag[0])
+			for i := 1; i < len(tag); i++ {
+				fmt.Printf(": %s", tag[i])
+			}
+			fmt.Println()
+		}
+	}
+
+	loc, err := location.ParsePublicEvent(event)
+	if err != nil {
+		fmt.Printf("\n❌ Invalid location: %v\n", err)
+	} else {
+		printCoordinates(loc.Geohash, loc.Accuracy)
+	}
+	fmt.Println("=============================================================")
+}
+
+// publicEventOutput reads the location tags of a kind 30472 event, without
+// the shorter geohash prefix g tags
+func publicEventOutput(event *nostr.Event) *eventOutput {
+	loc, err := location.ParsePublicEvent(event)
+	if err != nil {
+		return newEventOutput(event, nil, statusFailed, err)
+	}
+	return newEventOutput(event, loc.Tags(), statusPublic, nil)
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"noloc/location"
+)
+
+func TestPublicListenGeohashes(t *testing.T) {
+	// Helsinki, covered by ud9t, ud9v, ud9w, ud9x, ud9y and ud9z
+	helsinki := &geoFilter{box: location.BoundingBox{MinLat: 60.1, MinLon: 24.8, MaxLat: 60.3, MaxLon: 25.1}}
+
+	tests := []struct {
+		name   string
+		config publicListenConfig
+		want   func([]string) bool
+	}{
+		{"none", publicListenConfig{}, func(g []string) bool { return g == nil }},
+		{"prefix", publicListenConfig{geohashPrefix: "u4pr"}, func(g []string) bool { return slices.Equal(g, []string{"u4pr"}) }},
+		{"area", publicListenConfig{geo: helsinki}, func(g []string) bool { return slices.Equal(g, helsinki.prefixes()) }},
+		{"prefix containing the area", publicListenConfig{geohashPrefix: "u", geo: helsinki}, func(g []string) bool { return slices.Equal(g, helsinki.prefixes()) }},
+		{"prefix inside the area", publicListenConfig{geohashPrefix: "ud9wrz", geo: helsinki}, func(g []string) bool { return slices.Equal(g, []string{"ud9wrz"}) }},
+		{"disjoint", publicListenConfig{geohashPrefix: "u4pr", geo: helsinki}, func(g []string) bool { return slices.Equal(g, []string{"u4pr"}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.config.filter()
+			if got := filter.Tags["g"]; !tt.want(got) {
+				t.Errorf("#g = %v", got)
+			}
+		})
+	}
+}
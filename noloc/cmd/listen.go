@@ -2,68 +2,95 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
 	"github.com/mmcloughlin/geohash"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
-	"github.com/nbd-wtf/go-nostr/nip44"
 	"github.com/spf13/cobra"
+
+	"noloc/location"
 )
 
 var listenCmd = &cobra.Command{
 	Use:   "listen",
-	Short: "Listen for encrypted location messages",
+	Short: "Listen for encrypted or public location messages",
 	Long: `Subscribe to a Nostr relay and listen for encrypted location events
 addressed to your public key. Automatically decrypts messages using your
-private key and displays location data including coordinates.`,
+private key and displays location data including coordinates.
+
+With --public, listens for public location events (kind 30472) instead,
+optionally filtered by author, d-tag, hashtag and geohash prefix.
+
+--near/--radius and --bbox restrict both modes to a geographic area.
+Public events are matched by relays on geohash prefix g tags and checked
+by distance on arrival, encrypted events are checked after decryption.
+
+Encrypted locations sent with 'send --wrap' arrive in NIP-59 gift wraps
+(kind 1059), which are unwrapped and shown like any other location event.
+
+--output json or ndjson prints one object per event for other tools,
+with the decrypted tags, coordinates, geohash cell and decryption status.`,
 	RunE: runListen,
 }
 
 func init() {
 	rootCmd.AddCommand(listenCmd)
-	listenCmd.Flags().StringP("receiver", "r", "", "Receiver private key (nsec... or @identity)")
-	
-	listenCmd.MarkFlagRequired("receiver")
+	listenCmd.Flags().StringP("receiver", "r", "", "Receiver private key (nsec..., bunker:// URI or @identity), required unless --public")
+
+	// Public listening mode
+	listenCmd.Flags().Bool("public", false, "Listen for public location events (kind 30472)")
+	listenCmd.Flags().StringSlice("author", nil, "Only events from these authors (npub, nprofile, hex, user@domain or @identity), public mode")
+	listenCmd.Flags().StringSlice("identifier", nil, "Only events with these d-tags, public mode")
+	listenCmd.Flags().StringSlice("hashtag", nil, "Only events with these t-tags, public mode")
+	listenCmd.Flags().String("geohash", "", "Only events whose geohash starts with this prefix, public mode")
+
+	addGeoFlags(listenCmd)
+	addOutputFlag(listenCmd)
 }
 
 func runListen(cmd *cobra.Command, args []string) error {
 	// Load flags into config
 	LoadFlags(cmd)
 
+	if k.Bool("public") {
+		return runPublicListen()
+	}
+
 	// Access config with dot notation
 	receiver := k.String("receiver")
 	if receiver == "" {
-		return fmt.Errorf("receiver is required (--receiver or -r)")
+		return fmt.Errorf("receiver is required (--receiver or -r) unless --public is set")
 	}
 
-	relayURL := k.String("relay")
-	if relayURL == "" {
-		return fmt.Errorf("relay URL is required (--relay)")
+	relayURL, err := primaryRelayURL()
+	if err != nil {
+		return err
 	}
 
-	// Validate receiver format (should be nsec after resolution)
-	if !strings.HasPrefix(receiver, "nsec1") {
-		return fmt.Errorf("receiver must be an nsec private key (starting with 'nsec1') or @identity reference")
+	geo, err := parseGeoFilter()
+	if err != nil {
+		return err
 	}
 
-	_, receiverSKRaw, err := nip19.Decode(receiver)
+	format, err := parseOutputFormat()
 	if err != nil {
-		return fmt.Errorf("failed to decode receiver nsec: %w", err)
+		return err
 	}
-	receiverSK := receiverSKRaw.(string)
 
-	receiverPubkey, err := nostr.GetPublicKey(receiverSK)
+	// The receiver decrypts with a local key or through its remote signer
+	signer, err := resolveSigner(receiver)
 	if err != nil {
-		return fmt.Errorf("failed to get receiver public key: %w", err)
+		return fmt.Errorf("failed to resolve receiver: %w", err)
 	}
 
+	receiverPubkey := signer.PublicKey()
+
 	receiverNpub, err := nip19.EncodePublicKey(receiverPubkey)
 	if err != nil {
 		return fmt.Errorf("failed to encode receiver npub: %w", err)
@@ -72,6 +99,9 @@ func runListen(cmd *cobra.Command, args []string) error {
 	log.Printf("Starting location listener...")
 	log.Printf("Receiver npub: %s", receiverNpub)
 	log.Printf("Relay: %s", relayURL)
+	if geo != nil {
+		log.Printf("Area: %s", geo)
+	}
 	log.Println("Listening for encrypted location messages...")
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -85,64 +115,69 @@ func runListen(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
-	relay, err := nostr.RelayConnect(ctx, relayURL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to relay: %w", err)
-	}
-	defer relay.Close()
-
 	filters := []nostr.Filter{{
 		Kinds: []int{30473},
 		Tags: nostr.TagMap{
 			"p": []string{receiverPubkey},
 		},
+	}, {
+		Kinds: []int{nostr.KindGiftWrap},
+		Tags: nostr.TagMap{
+			"p": []string{receiverPubkey},
+		},
 	}}
 
-	sub, err := relay.Subscribe(ctx, filters)
-	if err != nil {
-		return fmt.Errorf("failed to subscribe: %w", err)
+	if format == outputText {
+		fmt.Println("=============================================================")
 	}
 
-	log.Println("Subscribed to location events. Press Ctrl+C to exit.")
-	fmt.Println("=============================================================")
+	// Label senders by identity, contact or ephemeral seed name
+	authors := newAuthorIndex()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case event := <-sub.Events:
-			if event == nil {
-				continue
+	return subscribeWithReconnect(ctx, relayURL, filters, func(event *nostr.Event) {
+		// Gift wraps also carry direct messages, only locations are shown
+		var wrapID string
+		if event.Kind == nostr.KindGiftWrap {
+			wrapID = event.ID
+			inner, err := location.UnwrapEvent(ctx, signer, event)
+			if err != nil {
+				if !errors.Is(err, location.ErrNotLocation) {
+					log.Printf("Skipping gift wrap %s: %v", event.ID, err)
+				}
+				return
 			}
-
-			outputFormatted(event, receiverSK)
+			event = inner
 		}
-	}
-}
-
-func decryptLocationContent(encryptedContent string, receiverSK string, senderPubkey string) ([][]interface{}, error) {
-	conversationKey, err := nip44.GenerateConversationKey(senderPubkey, receiverSK)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate conversation key: %w", err)
-	}
 
-	decryptedContent, err := nip44.Decrypt(encryptedContent, conversationKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt content: %w", err)
-	}
+		locationData, err := location.DecryptContentWith(ctx, signer, event.Content, event.PubKey)
 
-	var locationData [][]interface{}
-	if err := json.Unmarshal([]byte(decryptedContent), &locationData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal location data: %w", err)
-	}
+		// Area filtering needs the decrypted geohash
+		if geo != nil && (err != nil || !geo.contains(locationGeohash(locationData))) {
+			return
+		}
 
-	return locationData, nil
+		if format != outputText {
+			status := statusDecrypted
+			if err != nil {
+				status = statusFailed
+			}
+			out := newEventOutput(event, locationData, status, err)
+			out.AuthorName = authors.name(event)
+			out.WrapID = wrapID
+			writeEventOutput(format, out)
+			return
+		}
+		outputFormatted(event, wrapID, authors, locationData, err)
+	})
 }
 
-func outputFormatted(event *nostr.Event, receiverSK string) {
+func outputFormatted(event *nostr.Event, wrapID string, authors *authorIndex, locationData nostr.Tags, err error) {
 	fmt.Printf("\n📍 New Location Event Received\n")
 	fmt.Printf("Event ID: %s\n", event.ID)
-	fmt.Printf("From: %s\n", event.PubKey)
+	if wrapID != "" {
+		fmt.Printf("Gift wrap: %s (NIP-59)\n", wrapID)
+	}
+	fmt.Printf("From: %s\n", authors.label(event))
 	fmt.Printf("Created: %s\n", event.CreatedAt.Time().Format("2006-01-02 15:04:05"))
 
 	fmt.Printf("\nPublic Tags:\n")
@@ -156,7 +191,6 @@ func outputFormatted(event *nostr.Event, receiverSK string) {
 		}
 	}
 
-	locationData, err := decryptLocationContent(event.Content, receiverSK, event.PubKey)
 	if err != nil {
 		fmt.Printf("\n❌ Failed to decrypt: %v\n", err)
 	} else {
@@ -164,26 +198,63 @@ func outputFormatted(event *nostr.Event, receiverSK string) {
 		var geohashStr string
 		for _, tag := range locationData {
 			if len(tag) >= 2 {
-				fmt.Printf("  - %v: %v\n", tag[0], tag[1])
+				fmt.Printf("  - %s: %s\n", tag[0], tag[1])
 				if tag[0] == "g" {
-					geohashStr = fmt.Sprintf("%v", tag[1])
+					geohashStr = tag[1]
 				}
 				if len(tag) > 2 {
 					for i := 2; i < len(tag); i++ {
-						fmt.Printf("    + %v\n", tag[i])
+						fmt.Printf("    + %s\n", tag[i])
 					}
 				}
 			}
 		}
 
 		if geohashStr != "" {
-			lat, lon := geohash.Decode(geohashStr)
-			fmt.Printf("\n📌 Converted Coordinates:\n")
-			fmt.Printf("  - Latitude:  %.6f\n", lat)
-			fmt.Printf("  - Longitude: %.6f\n", lon)
-			fmt.Printf("  - Map: https://www.openstreetmap.org/?mlat=%.6f&mlon=%.6f&zoom=4\n", lat, lon)
+			printCoordinates(geohashStr, locationAccuracy(locationData))
 		}
 	}
 	fmt.Println("=============================================================")
 }
 
+// locationGeohash returns the longest g tag value of location tags
+func locationGeohash(tags nostr.Tags) string {
+	var gh string
+	for tag := range tags.FindAll("g") {
+		if len(tag[1]) > len(gh) {
+			gh = tag[1]
+		}
+	}
+	return gh
+}
+
+// locationAccuracy returns the accuracy tag of location tags, 0 if it is
+// missing or invalid
+func locationAccuracy(tags nostr.Tags) float64 {
+	tag := tags.Find("accuracy")
+	if tag == nil {
+		return 0
+	}
+	accuracy, err := location.ParseAccuracy(tag[1])
+	if err != nil {
+		return 0
+	}
+	return accuracy
+}
+
+// printCoordinates prints the decoded center of a geohash with its cell, the
+// effective uncertainty and a map link
+func printCoordinates(geohashStr string, accuracy float64) {
+	lat, lon := geohash.Decode(geohashStr)
+	box := location.GeohashBox(geohashStr)
+	fmt.Printf("\n📌 Converted Coordinates:\n")
+	fmt.Printf("  - Latitude:  %.6f\n", lat)
+	fmt.Printf("  - Longitude: %.6f\n", lon)
+	fmt.Printf("  - Geohash cell: %.6f,%.6f to %.6f,%.6f (±%s)\n",
+		box.MinLat, box.MinLon, box.MaxLat, box.MaxLon, formatDistance(location.GeohashError(geohashStr)))
+	if accuracy > 0 {
+		fmt.Printf("  - Accuracy: %s (68%%)\n", formatDistance(accuracy))
+	}
+	fmt.Printf("  - Uncertainty: %s (68%%)\n", formatDistance(location.Uncertainty(geohashStr, accuracy)))
+	fmt.Printf("  - Map: https://www.openstreetmap.org/?mlat=%.6f&mlon=%.6f&zoom=4\n", lat, lon)
+}
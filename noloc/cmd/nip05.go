@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// nip05Timeout bounds a NIP-05 lookup
+const nip05Timeout = 10 * time.Second
+
+// httpDoer sends HTTP requests. NIP-05 lookups go through nip05Client, which
+// can be replaced to resolve identifiers against a local server.
+type httpDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+var nip05Client httpDoer = &http.Client{Timeout: nip05Timeout}
+
+// nip05Pattern matches NIP-05 identifiers, local part and domain
+var nip05Pattern = regexp.MustCompile(`^([a-zA-Z0-9._-]+)@([a-zA-Z0-9.-]+(?::\d+)?)$`)
+
+// isNIP05 reports whether the value looks like a NIP-05 user@domain
+func isNIP05(value string) bool {
+	return nip05Pattern.MatchString(value)
+}
+
+// queryNIP05 resolves a NIP-05 identifier to a hex public key and the
+// relays its domain lists for it
+func queryNIP05(ctx context.Context, identifier string) (string, []string, error) {
+	match := nip05Pattern.FindStringSubmatch(identifier)
+	if match == nil {
+		return "", nil, fmt.Errorf("invalid NIP-05 identifier %q: must be name@domain", identifier)
+	}
+	name := strings.ToLower(match[1])
+	domain := strings.ToLower(match[2])
+
+	ctx, cancel := context.WithTimeout(ctx, nip05Timeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s", domain, url.QueryEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create NIP-05 request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := nip05Client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to look up %s: %w", identifier, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("failed to look up %s: %s returned status %d", identifier, domain, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read NIP-05 response: %w", err)
+	}
+
+	var response struct {
+		Names  map[string]string   `json:"names"`
+		Relays map[string][]string `json:"relays"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", nil, fmt.Errorf("invalid NIP-05 response from %s: %w", domain, err)
+	}
+
+	pubkey, ok := response.Names[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%s is not known at %s", name, domain)
+	}
+	if !nostr.IsValidPublicKey(pubkey) {
+		return "", nil, fmt.Errorf("invalid public key for %s in NIP-05 response", identifier)
+	}
+
+	return pubkey, response.Relays[pubkey], nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// startNIP05Server serves nostr.json responses by name over TLS and points
+// nip05Client at it. It returns the domain of the server.
+func startNIP05Server(t *testing.T, responses map[string]string) string {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/nostr.json" {
+			http.NotFound(w, r)
+			return
+		}
+		body, ok := responses[r.URL.Query().Get("name")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	oldClient := nip05Client
+	nip05Client = server.Client()
+	t.Cleanup(func() { nip05Client = oldClient })
+
+	return strings.TrimPrefix(server.URL, "https://")
+}
+
+func TestQueryNIP05(t *testing.T) {
+	bob := testIdentity("bob")
+	domain := startNIP05Server(t, map[string]string{
+		"bob":      fmt.Sprintf(`{"names":{"bob":"%s"},"relays":{"%s":["wss://relay.example"]}}`, bob.Hex, bob.Hex),
+		"norelays": fmt.Sprintf(`{"names":{"norelays":"%s"}}`, bob.Hex),
+		"other":    fmt.Sprintf(`{"names":{"bob":"%s"}}`, bob.Hex),
+		"broken":   `{"names":{"broken":`,
+		"badkey":   `{"names":{"badkey":"npub1notahexkey"}}`,
+	})
+
+	tests := []struct {
+		identifier string
+		pubkey     string
+		relays     []string
+		err        string
+	}{
+		{identifier: "bob@" + domain, pubkey: bob.Hex, relays: []string{"wss://relay.example"}},
+		{identifier: "Bob@" + strings.ToUpper(domain), pubkey: bob.Hex, relays: []string{"wss://relay.example"}},
+		{identifier: "norelays@" + domain, pubkey: bob.Hex},
+		{identifier: "other@" + domain, err: "other is not known"},
+		{identifier: "broken@" + domain, err: "invalid NIP-05 response"},
+		{identifier: "badkey@" + domain, err: "invalid public key"},
+		{identifier: "nobody@" + domain, err: "returned status 404"},
+		{identifier: "not an identifier", err: "invalid NIP-05 identifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			pubkey, relays, err := queryNIP05(context.Background(), tt.identifier)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pubkey != tt.pubkey || !slices.Equal(relays, tt.relays) {
+				t.Errorf("got %s %v, want %s %v", pubkey, relays, tt.pubkey, tt.relays)
+			}
+		})
+	}
+}
+
+func TestResolveProfileNIP05(t *testing.T) {
+	testConfig(t, "")
+	bob := testIdentity("bob")
+	domain := startNIP05Server(t, map[string]string{
+		"bob": fmt.Sprintf(`{"names":{"bob":"%s"},"relays":{"%s":["wss://relay.example"]}}`, bob.Hex, bob.Hex),
+	})
+
+	pubkey, relays, err := resolveProfile("bob@" + domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pubkey != bob.Hex || !slices.Equal(relays, []string{"wss://relay.example"}) {
+		t.Errorf("got %s %v", pubkey, relays)
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/spf13/cobra"
+
+	"noloc/location"
+)
+
+// Output formats of the listeners
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+// Decryption status of a received event
+const (
+	statusDecrypted = "decrypted" // Content of kind 30473 was decrypted
+	statusFailed    = "failed"    // Decryption or location parsing failed
+	statusNoKey     = "no-key"    // No known identity can decrypt the event
+	statusPublic    = "public"    // Kind 30472, nothing to decrypt
+)
+
+// eventOutput is the machine-readable form of a received location event
+type eventOutput struct {
+	ID          string                `json:"id"`
+	WrapID      string                `json:"wrap_id,omitempty"` // NIP-59 gift wrap the event came in
+	Author      string                `json:"author"`
+	AuthorName  string                `json:"author_name,omitempty"`
+	Kind        int                   `json:"kind"`
+	CreatedAt   int64                 `json:"created_at"`
+	D           string                `json:"d"`
+	Expiration  int64                 `json:"expiration,omitempty"`
+	Status      string                `json:"status"`
+	Error       string                `json:"error,omitempty"`
+	DecryptedBy string                `json:"decrypted_by,omitempty"`
+	Tags        nostr.Tags            `json:"tags,omitempty"`
+	Geohash     string                `json:"geohash,omitempty"`
+	Lat         *float64              `json:"lat,omitempty"`
+	Lon         *float64              `json:"lon,omitempty"`
+	BBox        *location.BoundingBox `json:"bbox,omitempty"`
+	CellError   float64               `json:"geohash_error_m,omitempty"` // Cell half-diagonal
+	Accuracy    float64               `json:"accuracy_m,omitempty"`      // Sender's accuracy tag
+	Uncertainty float64               `json:"uncertainty_m,omitempty"`   // Combined 68% radius
+}
+
+// addOutputFlag registers the output format flag on a command
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", outputText, "Output format: text, json or ndjson")
+}
+
+// parseOutputFormat reads and checks the output format flag
+func parseOutputFormat() (string, error) {
+	switch format := k.String("output"); format {
+	case outputText, outputJSON, outputNDJSON:
+		return format, nil
+	case "":
+		return outputText, nil
+	default:
+		return "", fmt.Errorf("invalid --output %q: must be text, json or ndjson", format)
+	}
+}
+
+// newEventOutput collects the event fields and the location read from the
+// given location tags, which are the decrypted content for kind 30473
+func newEventOutput(event *nostr.Event, tags nostr.Tags, status string, err error) *eventOutput {
+	out := &eventOutput{
+		ID:        event.ID,
+		Author:    event.PubKey,
+		Kind:      event.Kind,
+		CreatedAt: int64(event.CreatedAt),
+		D:         event.Tags.GetD(),
+		Status:    status,
+		Tags:      tags,
+	}
+	if expiration := location.Expiration(event); !expiration.IsZero() {
+		out.Expiration = expiration.Unix()
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+
+	if gh := locationGeohash(tags); location.ValidGeohash(gh) {
+		lat, lon := geohash.Decode(gh)
+		box := location.GeohashBox(gh)
+		out.Geohash = gh
+		out.Lat = &lat
+		out.Lon = &lon
+		out.BBox = &box
+		out.CellError = location.GeohashError(gh)
+		out.Accuracy = locationAccuracy(tags)
+		out.Uncertainty = location.Uncertainty(gh, out.Accuracy)
+	}
+
+	return out
+}
+
+// writeEventOutput prints the event as an indented JSON object, or as a
+// single line for ndjson
+func writeEventOutput(format string, out *eventOutput) {
+	var data []byte
+	var err error
+	if format == outputNDJSON {
+		data, err = json.Marshal(out)
+	} else {
+		data, err = json.MarshalIndent(out, "", "  ")
+	}
+	if err != nil {
+		log.Printf("Error encoding event %s: %v", out.ID, err)
+		return
+	}
+	fmt.Println(string(data))
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// testOutputEvent is a location event with fixed fields, it is not signed
+func testOutputEvent() *nostr.Event {
+	return &nostr.Event{
+		ID:        strings.Repeat("e", 64),
+		PubKey:    strings.Repeat("a", 64),
+		Kind:      30472,
+		CreatedAt: 1700000000,
+		Tags:      nostr.Tags{{"d", "office"}, {"expiration", "1700003600"}, {"g", "u4pruyd"}, {"accuracy", "20"}},
+	}
+}
+
+func TestEventOutputFields(t *testing.T) {
+	event := testOutputEvent()
+	locTags := nostr.Tags{{"g", "u4pruyd"}, {"accuracy", "20"}}
+
+	tests := []struct {
+		name    string
+		tags    nostr.Tags
+		status  string
+		err     error
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "public",
+			tags:    locTags,
+			status:  statusPublic,
+			present: []string{"id", "author", "kind", "created_at", "d", "expiration", "status", "tags", "geohash", "lat", "lon", "bbox"},
+			absent:  []string{"error", "decrypted_by", "author_name"},
+		},
+		{
+			name:    "no key",
+			status:  statusNoKey,
+			present: []string{"id", "author", "kind", "status"},
+			absent:  []string{"tags", "geohash", "lat", "lon", "bbox", "error"},
+		},
+		{
+			name:    "failed",
+			status:  statusFailed,
+			err:     errors.New("failed to decrypt content"),
+			present: []string{"status", "error"},
+			absent:  []string{"geohash", "lat", "lon"},
+		},
+		{
+			name:    "invalid geohash",
+			tags:    nostr.Tags{{"g", "u4pA"}},
+			status:  statusDecrypted,
+			present: []string{"tags"},
+			absent:  []string{"geohash", "lat", "lon", "bbox"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(newEventOutput(event, tt.tags, tt.status, tt.err))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range tt.present {
+				if _, ok := fields[key]; !ok {
+					t.Errorf("missing %q in %s", key, data)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := fields[key]; ok {
+					t.Errorf("unexpected %q in %s", key, data)
+				}
+			}
+			if fields["status"] != tt.status {
+				t.Errorf("status = %v, want %s", fields["status"], tt.status)
+			}
+		})
+	}
+
+	out := newEventOutput(event, locTags, statusPublic, nil)
+	if out.D != "office" || out.Expiration != 1700003600 || out.Geohash != "u4pruyd" {
+		t.Errorf("output = %+v", out)
+	}
+	if !out.BBox.Contains(*out.Lat, *out.Lon) {
+		t.Errorf("center %v, %v outside the cell %+v", *out.Lat, *out.Lon, *out.BBox)
+	}
+}
+
+func TestWriteEventOutput(t *testing.T) {
+	out := newEventOutput(testOutputEvent(), nostr.Tags{{"g", "u4pruyd"}}, statusPublic, nil)
+
+	ndjson := captureOutput(t, func() {
+		writeEventOutput(outputNDJSON, out)
+		writeEventOutput(outputNDJSON, out)
+	})
+	lines := strings.Split(strings.TrimSuffix(ndjson, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ndjson output has %d lines, want one per event:\n%s", len(lines), ndjson)
+	}
+	for _, line := range lines {
+		var decoded eventOutput
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil || decoded.ID != out.ID {
+			t.Errorf("line %q: %v", line, err)
+		}
+	}
+
+	indented := captureOutput(t, func() { writeEventOutput(outputJSON, out) })
+	if !strings.HasPrefix(indented, "{\n  \"id\": ") {
+		t.Errorf("json output is not indented:\n%s", indented)
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", outputText, false},
+		{"text", outputText, false},
+		{"json", outputJSON, false},
+		{"ndjson", outputNDJSON, false},
+		{"yaml", "", true},
+	}
+	for _, tt := range tests {
+		testConfig(t, "")
+		k.Set("output", tt.value)
+		got, err := parseOutputFormat()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseOutputFormat(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestEventOutputGolden(t *testing.T) {
+	out := newEventOutput(testOutputEvent(), nostr.Tags{{"g", "u4pruyd"}, {"accuracy", "20"}}, statusPublic, nil)
+	got := captureOutput(t, func() { writeEventOutput(outputNDJSON, out) })
+
+	want := `{"id":"eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee",` +
+		`"author":"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",` +
+		`"kind":30472,"created_at":1700000000,"d":"office","expiration":1700003600,"status":"public",` +
+		`"tags":[["g","u4pruyd"],["accuracy","20"]],"geohash":"u4pruyd","lat":57.649,"lon":10.407,` +
+		`"bbox":{"min_lat":57.64801025390625,"min_lon":10.40679931640625,"max_lat":57.649383544921875,"max_lon":10.408172607421875},` +
+		`"geohash_error_m":86.59588056623451,"accuracy_m":20,"uncertainty_m":53.84807805620535}` + "\n"
+	if !strings.HasSuffix(got, "}\n") || strings.Count(got, "\n") != 1 {
+		t.Fatalf("output is not one line: %q", got)
+	}
+
+	// The distances may differ in the last digits between platforms
+	var gotFields, wantFields map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &gotFields); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal([]byte(want), &wantFields)
+	for _, key := range []string{"geohash_error_m", "uncertainty_m"} {
+		if g, w := gotFields[key].(float64), wantFields[key].(float64); math.Abs(g-w) > 1e-6 {
+			t.Errorf("%s = %v, want %v", key, g, w)
+		}
+		delete(gotFields, key)
+		delete(wantFields, key)
+	}
+	if !reflect.DeepEqual(gotFields, wantFields) {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The combined radius is the cell's RMS error and the accuracy
+	cell := out.CellError / math.Sqrt(3)
+	if math.Abs(out.Uncertainty-math.Sqrt(cell*cell+20*20)) > 1e-9 {
+		t.Errorf("uncertainty = %v", out.Uncertainty)
+	}
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 2 * time.Minute
+	connectTimeout    = 10 * time.Second
+)
+
+// relayPool keeps one open connection per relay URL and shares it between
+// all publishes. Broken connections are redialed on the next use, with
+// exponential backoff after failed attempts.
+type relayPool struct {
+	mu    sync.Mutex
+	conns map[string]*poolConn
+}
+
+// poolConn is the connection state of a single relay
+type poolConn struct {
+	mu       sync.Mutex
+	url      string
+	relay    *nostr.Relay
+	failures int
+	retryAt  time.Time
+}
+
+// pool is shared by all publishing commands
+var pool = newRelayPool()
+
+func newRelayPool() *relayPool {
+	return &relayPool{conns: make(map[string]*poolConn)}
+}
+
+func (p *relayPool) conn(url string) *poolConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	url = nostr.NormalizeURL(url)
+	c, ok := p.conns[url]
+	if !ok {
+		c = &poolConn{url: url}
+		p.conns[url] = c
+	}
+	return c
+}
+
+// ensure returns a connected relay, dialing it when there is no live
+// connection. During the backoff delay after failed attempts it waits for
+// the next attempt, unless that is past the context deadline.
+func (p *relayPool) ensure(ctx context.Context, url string) (*nostr.Relay, error) {
+	c := p.conn(url)
+	for {
+		relay, wait, err := c.connect(ctx)
+		if wait <= 0 {
+			return relay, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return nil, fmt.Errorf("reconnecting to %s in %s", c.url, wait.Round(time.Second))
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// connect returns the live connection or dials a new one. Before the
+// backoff delay has passed it returns the time left instead.
+func (c *poolConn) connect(ctx context.Context) (*nostr.Relay, time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.relay != nil && c.relay.IsConnected() {
+		return c.relay, 0, nil
+	}
+	if c.relay != nil {
+		log.Printf("Lost connection to %s, reconnecting", c.url)
+		c.relay = nil
+	}
+
+	if wait := time.Until(c.retryAt); wait > 0 {
+		return nil, wait, nil
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	relay, err := nostr.RelayConnect(dialCtx, c.url)
+	if err != nil {
+		c.failures++
+		c.retryAt = time.Now().Add(reconnectDelay(c.failures))
+		return nil, 0, fmt.Errorf("failed to connect to relay: %w", err)
+	}
+
+	c.relay = relay
+	c.failures = 0
+	c.retryAt = time.Time{}
+	return relay, 0, nil
+}
+
+// publish sends the event over the pooled connection and waits for the OK
+func (p *relayPool) publish(ctx context.Context, url string, event *nostr.Event) error {
+	relay, err := p.ensure(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	if err := relay.Publish(publishCtx, *event); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// close closes every open connection
+func (p *relayPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, c := range p.conns {
+		c.mu.Lock()
+		if c.relay != nil {
+			c.relay.Close()
+			c.relay = nil
+		}
+		c.mu.Unlock()
+	}
+}
+
+// reconnectDelay doubles from minReconnectDelay up to maxReconnectDelay
+func reconnectDelay(failures int) time.Duration {
+	delay := minReconnectDelay
+	for i := 1; i < failures && delay < maxReconnectDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxReconnectDelay {
+		delay = maxReconnectDelay
+	}
+	return delay
+}
+
+// sleepContext waits for the duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
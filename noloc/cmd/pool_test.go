@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelayPoolBackoff(t *testing.T) {
+	p := newRelayPool()
+	url := closedRelayURL(t)
+
+	if _, err := p.ensure(context.Background(), url); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Fatalf("first attempt: %v, want connection error", err)
+	}
+
+	// The next attempt is after the deadline, fail without waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.ensure(ctx, url); err == nil || !strings.Contains(err.Error(), "reconnecting") {
+		t.Fatalf("within backoff: %v, want reconnecting error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("returned after %s, want at once", elapsed)
+	}
+
+	// The next attempt is before the deadline, wait for it and dial again
+	c := p.conn(url)
+	c.mu.Lock()
+	c.retryAt = time.Now().Add(200 * time.Millisecond)
+	c.mu.Unlock()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start = time.Now()
+	if _, err := p.ensure(ctx, url); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Fatalf("after backoff: %v, want connection error", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("dialed after %s, before the backoff delay", elapsed)
+	}
+	if c.failures != 2 {
+		t.Errorf("failures = %d, want 2", c.failures)
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{8, maxReconnectDelay},
+		{100, maxReconnectDelay},
+	}
+	for _, tt := range tests {
+		if got := reconnectDelay(tt.failures); got != tt.want {
+			t.Errorf("reconnectDelay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
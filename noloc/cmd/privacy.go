@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"noloc/location"
+)
+
+// privacyPolicy returns the strictest of the default privacy policy and the
+// policies of the named identities and contacts, configured in ~/.noloc.yaml:
+//
+//	privacy:
+//	  default:
+//	    precision: 9
+//	  alice:          # positions sent by @alice
+//	    fuzz: 200     # random offset within 200 m
+//	    grid: 500     # snapped to 500 m grid cells
+//	  bob:            # positions sent to @bob
+//	    precision: 5
+//
+// Empty names are skipped.
+func privacyPolicy(names ...string) location.Policy {
+	policy := configuredPolicy("default")
+	for _, name := range names {
+		if name != "" {
+			policy = policy.Strictest(configuredPolicy(name))
+		}
+	}
+	return policy
+}
+
+// configuredPolicy reads the policy under privacy.<name>
+func configuredPolicy(name string) location.Policy {
+	prefix := "privacy." + name + "."
+	return location.Policy{
+		Fuzz:      k.Float64(prefix + "fuzz"),
+		Grid:      k.Float64(prefix + "grid"),
+		Precision: k.Int(prefix + "precision"),
+	}
+}
+
+// identityName returns the name of the identity or contact with the public
+// key, "" when it is not stored
+func identityName(pubkey string) string {
+	identities, err := readIdentities()
+	if err != nil {
+		return ""
+	}
+	for name, id := range identities {
+		if id.Hex == pubkey {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"testing"
+
+	"noloc/location"
+)
+
+func TestPrivacyPolicy(t *testing.T) {
+	testConfig(t, "")
+	writeConfig(t, `
+privacy:
+  default:
+    precision: 9
+  alice:
+    fuzz: 200
+    grid: 500
+  bob:
+    precision: 5
+    fuzz: 100
+`)
+
+	tests := []struct {
+		names []string
+		want  location.Policy
+	}{
+		{nil, location.Policy{Precision: 9}},
+		{[]string{""}, location.Policy{Precision: 9}},
+		{[]string{"carol"}, location.Policy{Precision: 9}},
+		{[]string{"alice"}, location.Policy{Fuzz: 200, Grid: 500, Precision: 9}},
+		{[]string{"alice", "bob"}, location.Policy{Fuzz: 200, Grid: 500, Precision: 5}},
+		{[]string{"", "bob"}, location.Policy{Fuzz: 100, Precision: 5}},
+	}
+	for _, tt := range tests {
+		if got := privacyPolicy(tt.names...); got != tt.want {
+			t.Errorf("privacyPolicy(%q) = %+v, want %+v", tt.names, got, tt.want)
+		}
+	}
+}
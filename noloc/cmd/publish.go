@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// publishTimeout bounds connecting and waiting for the OK of one relay
+	publishTimeout = 10 * time.Second
+	// publishDeadline bounds a whole publish, including the wait for a
+	// relay that is reconnecting after failed attempts
+	publishDeadline = 30 * time.Second
+)
+
+// publishResult is the outcome of publishing one event to one relay
+type publishResult struct {
+	relayURL string
+	err      error
+}
+
+// message returns the relay's NIP-01 OK message, or the connection error
+func (r publishResult) message() string {
+	if r.err == nil {
+		return "accepted"
+	}
+	msg := r.err.Error()
+	if i := strings.Index(msg, "msg: "); i >= 0 {
+		return msg[i+len("msg: "):]
+	}
+	return msg
+}
+
+// relayURLs returns the configured relays. The relay key may come from a
+// repeated --relay flag, a comma-separated value or a list in ~/.noloc.yaml.
+func relayURLs() ([]string, error) {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, url := range stringList("relay") {
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		urls = append(urls, url)
+	}
+
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("relay URL is required (--relay)")
+	}
+	return urls, nil
+}
+
+// withRelayHints appends relay hints of a receiver, such as nprofile or
+// NIP-05 relays, to the configured relays
+func withRelayHints(urls, hints []string) []string {
+	merged := append([]string(nil), urls...)
+	for _, hint := range hints {
+		hint = strings.TrimRight(strings.TrimSpace(hint), "/")
+		if hint == "" || !strings.HasPrefix(hint, "ws") || containsString(merged, hint) {
+			continue
+		}
+		merged = append(merged, hint)
+	}
+	return merged
+}
+
+// primaryRelayURL returns the first configured relay, used by commands that
+// read from a single relay
+func primaryRelayURL() (string, error) {
+	urls, err := relayURLs()
+	if err != nil {
+		return "", err
+	}
+	return urls[0], nil
+}
+
+// publishQuorum returns how many relays must accept an event, all of them
+// when --quorum is 0 or larger than the relay count
+func publishQuorum(relayCount int) int {
+	quorum := k.Int("quorum")
+	if quorum <= 0 || quorum > relayCount {
+		return relayCount
+	}
+	return quorum
+}
+
+// publishToRelays publishes the event to all relays concurrently over the
+// shared connection pool
+func publishToRelays(relayURLs []string, event *nostr.Event) ([]publishResult, error) {
+	results := make([]publishResult, len(relayURLs))
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishDeadline)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i, relayURL := range relayURLs {
+		wg.Add(1)
+		go func(i int, relayURL string) {
+			defer wg.Done()
+			err := pool.publish(ctx, relayURL, event)
+			results[i] = publishResult{relayURL: relayURL, err: err}
+		}(i, relayURL)
+	}
+	wg.Wait()
+
+	return results, checkQuorum(results)
+}
+
+// checkQuorum returns an error if fewer relays than the quorum accepted
+func checkQuorum(results []publishResult) error {
+	accepted := countAccepted(results)
+	quorum := publishQuorum(len(results))
+	if accepted < quorum {
+		return fmt.Errorf("accepted by %d/%d relays, quorum is %d", accepted, len(results), quorum)
+	}
+	return nil
+}
+
+func countAccepted(results []publishResult) int {
+	accepted := 0
+	for _, r := range results {
+		if r.err == nil {
+			accepted++
+		}
+	}
+	return accepted
+}
+
+// logPublishFailures logs every relay that did not accept the event
+func logPublishFailures(results []publishResult) {
+	for _, r := range results {
+		if r.err != nil {
+			log.Printf("  Relay %s: %s", r.relayURL, r.message())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// closedRelayURL returns a relay URL on a local port nobody listens on
+func closedRelayURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "ws://" + listener.Addr().String()
+	listener.Close()
+	return url
+}
+
+// testEvent returns a signed kind 1 event
+func testEvent(t *testing.T) *nostr.Event {
+	t.Helper()
+	event := &nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "test"}
+	if err := event.Sign(nostr.GeneratePrivateKey()); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestPublishQuorum(t *testing.T) {
+	// Each case gets its own closed relay, the pool would wait out the
+	// backoff of one that failed before
+	up1, up2, down := startTestRelay(t), startTestRelay(t), "down"
+
+	tests := []struct {
+		name     string
+		quorum   int
+		relays   []string
+		accepted int
+		wantErr  bool
+	}{
+		{name: "all accept", quorum: 0, relays: []string{up1, up2}, accepted: 2},
+		{name: "quorum met", quorum: 2, relays: []string{up1, up2, down}, accepted: 2},
+		{name: "quorum 0 means all", quorum: 0, relays: []string{up1, up2, down}, accepted: 2, wantErr: true},
+		{name: "too few accept", quorum: 2, relays: []string{up1, down}, accepted: 1, wantErr: true},
+		{name: "quorum above relay count", quorum: 5, relays: []string{up1, up2}, accepted: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConfig(t, "")
+			k.Set("quorum", tt.quorum)
+
+			relays := make([]string, len(tt.relays))
+			closed := closedRelayURL(t)
+			for i, url := range tt.relays {
+				relays[i] = url
+				if url == down {
+					relays[i] = closed
+				}
+			}
+
+			results, err := publishToRelays(relays, testEvent(t))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(results) != len(tt.relays) {
+				t.Fatalf("%d results for %d relays", len(results), len(tt.relays))
+			}
+			if got := countAccepted(results); got != tt.accepted {
+				t.Errorf("accepted by %d relays, want %d", got, tt.accepted)
+			}
+			for i, r := range results {
+				if r.relayURL != relays[i] {
+					t.Errorf("result %d for %s, want %s", i, r.relayURL, relays[i])
+				}
+				if (r.err == nil) != (r.relayURL != closed) {
+					t.Errorf("relay %s: %v", r.relayURL, r.err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckQuorum(t *testing.T) {
+	testConfig(t, "")
+	failed := publishResult{relayURL: "wss://b", err: fmt.Errorf("msg: blocked: spam")}
+	results := []publishResult{{relayURL: "wss://a"}, failed, failed}
+
+	if err := checkQuorum(results); err == nil || !strings.Contains(err.Error(), "accepted by 1/3 relays, quorum is 3") {
+		t.Errorf("quorum 0: %v", err)
+	}
+	k.Set("quorum", 1)
+	if err := checkQuorum(results); err != nil {
+		t.Errorf("quorum 1: %v", err)
+	}
+	if msg := failed.message(); msg != "blocked: spam" {
+		t.Errorf("message = %q, want the relay's OK message", msg)
+	}
+}
@@ -1,18 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
 	"math/rand"
 	"strconv"
-	"strings"
 	"time"
 
-	"github.com/mmcloughlin/geohash"
-	"github.com/nbd-wtf/go-nostr"
-	"github.com/nbd-wtf/go-nostr/nip19"
 	"github.com/spf13/cobra"
+
+	"noloc/location"
 )
 
 var randomCmd = &cobra.Command{
@@ -20,7 +19,10 @@ var randomCmd = &cobra.Command{
 	Short: "Send randomly moving public location events",
 	Long: `Generates and broadcasts random location movements as public Nostr events.
 Creates multiple concurrent moving objects, each with its own location that
-moves independently in small increments to simulate movement patterns.`,
+moves independently in small increments to simulate movement patterns. It is
+'noloc share random --public', with events that expire after two intervals.
+The summary of each event names its iteration, the position is only in the
+g tag.`,
 	RunE: runRandom,
 }
 
@@ -28,7 +30,7 @@ func init() {
 	rootCmd.AddCommand(randomCmd)
 	randomCmd.Flags().IntP("count", "c", 3, "Number of concurrent moving objects")
 	randomCmd.Flags().IntP("interval", "i", defaultInterval, "Update interval in seconds")
-	randomCmd.Flags().StringP("sender", "s", "", "Sender private key (nsec... or @identity)")
+	randomCmd.Flags().StringP("sender", "s", "", "Sender private key (nsec..., bunker:// URI or @identity)")
 	randomCmd.Flags().Int("accuracy", 0, "Location accuracy in meters")
 	randomCmd.Flags().Int("precision", 0, "Geohash precision (number of characters, 1-12)")
 	randomCmd.Flags().String("identifier", "walker", "Base identifier for addressable events (will be suffixed with number)")
@@ -46,113 +48,91 @@ type walker struct {
 func runRandom(cmd *cobra.Command, args []string) error {
 	LoadFlags(cmd)
 
-	// Validate configuration
-	config, err := validateRandomConfig()
+	config, err := loadShareConfig(true)
 	if err != nil {
 		return err
 	}
+	config.ttl = 2 * updateInterval()
 
 	log.Printf("Starting random location broadcaster...")
-	log.Printf("Mode: Public broadcast (kind 30472)")
-	log.Printf("Concurrent walkers: %d", config.count)
-	log.Printf("Update interval: %d seconds", config.interval)
-	log.Printf("Relay: %s", config.relayURL)
-	log.Printf("Base identifier: %s", config.identifier)
-
-	// Create walkers with random starting positions
-	walkers := make([]walker, config.count)
-	for i := 0; i < config.count; i++ {
-		walkers[i] = walker{
-			index: i, // Use index as d-tag (0-based)
-			name:  fmt.Sprintf("%s-%d", config.identifier, i+1),
-			lat:   rand.Float64()*180 - 90,  // -90 to 90
-			lon:   rand.Float64()*360 - 180, // -180 to 180
-		}
-		log.Printf("Walker #%d (%s) starting at: Lat=%.6f, Lon=%.6f",
-			walkers[i].index, walkers[i].name, walkers[i].lat, walkers[i].lon)
-	}
-
-	// Main update loop - runs indefinitely
-	iteration := 0
-	for {
-		iteration++
-		log.Printf("\n--- Iteration %d ---", iteration)
+	config.logMode()
+	log.Printf("Update interval: %s", updateInterval())
 
-		// Update all walkers
-		for i := range walkers {
-			// Move the walker randomly
-			walkers[i].lat, walkers[i].lon = moveRandomly(walkers[i].lat, walkers[i].lon)
-
-			// Send the location event
-			processWalkerUpdate(config, &walkers[i], iteration)
-		}
-
-		time.Sleep(time.Duration(config.interval) * time.Second)
+	source, err := newRandomSource()
+	if err != nil {
+		return err
 	}
+	return share(source, config)
 }
 
-type randomConfig struct {
-	senderSK   string
-	relayURL   string
-	interval   int
-	count      int
-	accuracy_m int
-	precision  int
-	identifier string
+// randomSource moves all walkers every interval and returns their
+// positions one by one
+type randomSource struct {
+	walkers   []walker
+	interval  time.Duration
+	next      int // Index of the next walker to return
+	iteration int
 }
 
-func validateRandomConfig() (*randomConfig, error) {
-	sender := k.String("sender")
-	if sender == "" {
-		return nil, fmt.Errorf("sender is required (--sender or -s)")
-	}
-
-	relayURL := k.String("relay")
-	if relayURL == "" {
-		return nil, fmt.Errorf("relay URL is required (--relay)")
-	}
-
-	// Validate sender format (should be nsec after resolution)
-	if !strings.HasPrefix(sender, "nsec1") {
-		return nil, fmt.Errorf("sender must be an nsec private key (starting with 'nsec1') or @identity reference")
+func newRandomSource() (PositionSource, error) {
+	count := k.Int("count")
+	if count <= 0 {
+		return nil, fmt.Errorf("count must be positive (number of concurrent walkers)")
 	}
 
-	_, senderSK, err := nip19.Decode(sender)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode sender nsec: %w", err)
+	identifier := k.String("identifier")
+	if identifier == "" {
+		identifier = "walker"
 	}
+	log.Printf("Concurrent walkers: %d", count)
+	log.Printf("Base identifier: %s", identifier)
 
-	interval := k.Int("interval")
-	if interval == 0 {
-		interval = defaultInterval
+	// Create walkers with random starting positions
+	walkers := make([]walker, count)
+	for i := 0; i < count; i++ {
+		walkers[i] = walker{
+			index: i, // Use index as d-tag (0-based)
+			name:  fmt.Sprintf("%s-%d", identifier, i+1),
+			lat:   rand.Float64()*180 - 90,  // -90 to 90
+			lon:   rand.Float64()*360 - 180, // -180 to 180
+		}
+		log.Printf("Walker #%d (%s) starting at: Lat=%.6f, Lon=%.6f",
+			walkers[i].index, walkers[i].name, walkers[i].lat, walkers[i].lon)
 	}
 
-	count := k.Int("count")
-	if count <= 0 {
-		return nil, fmt.Errorf("count must be positive (number of concurrent walkers)")
-	}
+	return &randomSource{walkers: walkers, interval: updateInterval(), next: count}, nil
+}
 
-	accuracy_m := k.Int("accuracy")
-	precision := k.Int("precision")
+func (s *randomSource) Next(ctx context.Context) (*Position, error) {
+	if s.next == len(s.walkers) {
+		if s.iteration > 0 {
+			if err := sleepContext(ctx, s.interval); err != nil {
+				return nil, err
+			}
+		}
+		s.iteration++
+		s.next = 0
+		log.Printf("\n--- Iteration %d ---", s.iteration)
 
-	// Validate precision if provided
-	if precision != 0 && (precision < 1 || precision > 12) {
-		return nil, fmt.Errorf("precision must be between 1 and 12 characters")
+		// Move all walkers randomly
+		for i := range s.walkers {
+			s.walkers[i].lat, s.walkers[i].lon = moveRandomly(s.walkers[i].lat, s.walkers[i].lon)
+		}
 	}
 
-	identifier := k.String("identifier")
-	if identifier == "" {
-		identifier = "walker"
-	}
+	w := &s.walkers[s.next]
+	s.next++
 
-	return &randomConfig{
-		senderSK:   senderSK.(string),
-		relayURL:   relayURL,
-		interval:   interval,
-		count:      count,
-		accuracy_m: accuracy_m,
-		precision:  precision,
-		identifier: identifier,
+	// Use walker index as d-tag so events replace each other
+	return &Position{
+		Track: strconv.Itoa(w.index),
+		Lat:   w.lat,
+		Lon:   w.lon,
+		Details: location.Location{
+			Title:    w.name,
+			Summary:  fmt.Sprintf("Iteration %d", s.iteration),
+			Hashtags: []string{"random", "test", "location"}, // Hashtags for discoverability
+		},
 	}, nil
 }
 
@@ -180,73 +160,3 @@ func moveRandomly(lat, lon float64) (float64, float64) {
 
 	return newLat, newLon
 }
-
-func processWalkerUpdate(config *randomConfig, w *walker, iteration int) {
-	log.Printf("  Walker #%d (%s): Lat=%.6f, Lon=%.6f", w.index, w.name, w.lat, w.lon)
-
-	ttl := 2 * config.interval
-	event, err := createWalkerLocationEvent(config, w, ttl, iteration)
-	if err != nil {
-		log.Printf("  Error creating location event for walker #%d: %v", w.index, err)
-		return
-	}
-
-	if err := publishToRelay(config.relayURL, event); err != nil {
-		log.Printf("  Error publishing event for walker #%d: %v", w.index, err)
-	} else {
-		log.Printf("  Successfully published event for walker #%d (ID: %s)", w.index, event.ID)
-	}
-}
-
-func createWalkerLocationEvent(config *randomConfig, w *walker, ttl int, iteration int) (*nostr.Event, error) {
-	// Generate geohash with specified precision or default
-	var gh string
-	if config.precision > 0 {
-		gh = geohash.EncodeWithPrecision(w.lat, w.lon, uint(config.precision))
-	} else {
-		gh = geohash.Encode(w.lat, w.lon)
-	}
-
-	// Get sender public key
-	senderPubkey, err := nostr.GetPublicKey(config.senderSK)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get sender public key: %w", err)
-	}
-
-	expiration := time.Now().Add(time.Duration(ttl) * time.Second).Unix()
-
-	// Build tags for public event (kind 30472)
-	// Use walker index as d-tag so events replace each other
-	tags := nostr.Tags{
-		{"g", gh},                                      // Required geohash
-		{"d", strconv.Itoa(w.index)},                  // Use index as d-tag (0-based)
-		{"expiration", fmt.Sprintf("%d", expiration)}, // Expiration time
-		{"title", w.name},                              // Walker name
-		{"summary", fmt.Sprintf("Iteration %d: %.6f, %.6f", iteration, w.lat, w.lon)}, // Location summary
-	}
-
-	// Add accuracy tag if specified
-	if config.accuracy_m > 0 {
-		tags = append(tags, nostr.Tag{"accuracy", strconv.Itoa(config.accuracy_m)})
-	}
-
-	// Add hashtags for discoverability
-	tags = append(tags, nostr.Tag{"t", "random"})
-	tags = append(tags, nostr.Tag{"t", "test"})
-	tags = append(tags, nostr.Tag{"t", "location"})
-
-	// Create public location event (kind 30472)
-	event := &nostr.Event{
-		PubKey:    senderPubkey,
-		CreatedAt: nostr.Timestamp(time.Now().Unix()),
-		Kind:      30472, // Public location event kind
-		Tags:      tags,
-		Content:   "",    // No content field for public events
-	}
-
-	if err := event.Sign(config.senderSK); err != nil {
-		return nil, fmt.Errorf("failed to sign event: %w", err)
-	}
-
-	return event, nil
-}
\ No newline at end of file
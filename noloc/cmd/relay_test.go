@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// testRelay is a minimal in-memory Nostr relay. It stores every event and
+// sends stored and new events to matching subscriptions. Limit 0 is
+// ignored, so that a reply published just before its subscription arrives
+// still reaches the subscriber.
+type testRelay struct {
+	mu     sync.Mutex
+	events []*nostr.Event
+	subs   map[*testRelaySub]bool
+	conns  map[*testRelayConn]bool
+}
+
+type testRelaySub struct {
+	conn    *testRelayConn
+	id      string
+	filters nostr.Filters
+}
+
+type testRelayConn struct {
+	mu sync.Mutex
+	ws *websocket.Conn
+}
+
+func (c *testRelayConn) send(envelope nostr.Envelope) {
+	data, _ := envelope.MarshalJSON()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ws.Write(context.Background(), websocket.MessageText, data)
+}
+
+// startTestRelay serves a test relay until the test ends and returns its
+// ws:// URL
+func startTestRelay(t *testing.T) string {
+	_, url := newTestRelay(t)
+	return url
+}
+
+// newTestRelay is startTestRelay for tests that also publish to the relay
+// directly or drop its connections
+func newTestRelay(t *testing.T) (*testRelay, string) {
+	t.Helper()
+	relay := &testRelay{subs: make(map[*testRelaySub]bool), conns: make(map[*testRelayConn]bool)}
+	server := httptest.NewServer(http.HandlerFunc(relay.serve))
+	t.Cleanup(server.Close)
+	return relay, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func (r *testRelay) serve(w http.ResponseWriter, req *http.Request) {
+	ws, err := websocket.Accept(w, req, nil)
+	if err != nil {
+		return
+	}
+	conn := &testRelayConn{ws: ws}
+	r.mu.Lock()
+	r.conns[conn] = true
+	r.mu.Unlock()
+	defer r.drop(conn)
+	defer ws.CloseNow()
+
+	parser := nostr.NewMessageParser()
+	for {
+		_, data, err := ws.Read(context.Background())
+		if err != nil {
+			return
+		}
+		envelope, err := parser.ParseMessage(string(data))
+		if err != nil {
+			continue
+		}
+
+		switch env := envelope.(type) {
+		case *nostr.EventEnvelope:
+			event := env.Event
+			ok, _ := event.CheckSignature()
+			conn.send(&nostr.OKEnvelope{EventID: event.ID, OK: ok})
+			if ok {
+				r.publish(&event)
+			}
+		case *nostr.ReqEnvelope:
+			r.subscribe(&testRelaySub{conn: conn, id: env.SubscriptionID, filters: env.Filters})
+		case *nostr.CloseEnvelope:
+			r.unsubscribe(conn, string(*env))
+		}
+	}
+}
+
+func (r *testRelay) publish(event *nostr.Event) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	var matching []*testRelaySub
+	for sub := range r.subs {
+		if sub.filters.Match(event) {
+			matching = append(matching, sub)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, sub := range matching {
+		sub.conn.send(&nostr.EventEnvelope{SubscriptionID: &sub.id, Event: *event})
+	}
+}
+
+func (r *testRelay) subscribe(sub *testRelaySub) {
+	r.mu.Lock()
+	r.subs[sub] = true
+	var stored []*nostr.Event
+	for _, event := range r.events {
+		if sub.filters.Match(event) {
+			stored = append(stored, event)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, event := range stored {
+		sub.conn.send(&nostr.EventEnvelope{SubscriptionID: &sub.id, Event: *event})
+	}
+	eose := nostr.EOSEEnvelope(sub.id)
+	sub.conn.send(&eose)
+}
+
+func (r *testRelay) unsubscribe(conn *testRelayConn, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for sub := range r.subs {
+		if sub.conn == conn && sub.id == id {
+			delete(r.subs, sub)
+		}
+	}
+}
+
+func (r *testRelay) drop(conn *testRelayConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, conn)
+	for sub := range r.subs {
+		if sub.conn == conn {
+			delete(r.subs, sub)
+		}
+	}
+}
+
+// disconnect closes every client connection, as a relay restart would
+func (r *testRelay) disconnect() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for conn := range r.conns {
+		conn.ws.CloseNow()
+	}
+}
+
+// stored returns the events published to the relay
+func (r *testRelay) stored() []*nostr.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*nostr.Event(nil), r.events...)
+}
@@ -3,16 +3,14 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/nbd-wtf/go-nostr"
-	"github.com/nbd-wtf/go-nostr/nip19"
 	"github.com/spf13/cobra"
 )
 
 var resetCmd = &cobra.Command{
-	Use:   "reset <@name|nsec>",
+	Use:   "reset <@name|nsec|bunker://...>",
 	Short: "Delete all events created by a user",
 	Long:  "Query all events created by a user and send delete request events (kind 5) for each one",
 	Args:  cobra.ExactArgs(1),
@@ -31,47 +29,17 @@ func init() {
 func runReset(cmd *cobra.Command, args []string) error {
 	LoadFlags(cmd)
 
-	input := args[0]
-	var nsec string
-
-	// Check if input is an identity reference or nsec
-	if strings.HasPrefix(input, "@") {
-		// It's an identity reference
-		name := strings.TrimPrefix(input, "@")
-		identities, err := loadIdentities()
-		if err != nil {
-			return fmt.Errorf("failed to load identities: %w", err)
-		}
-
-		identity, exists := identities[name]
-		if !exists {
-			return fmt.Errorf("identity '%s' not found", name)
-		}
-		nsec = identity.Nsec
-	} else if strings.HasPrefix(input, "nsec1") {
-		// It's a direct nsec
-		nsec = input
-	} else {
-		return fmt.Errorf("invalid input: must be @name reference or nsec")
-	}
-
-	// Decode nsec to get private key
-	_, skRaw, err := nip19.Decode(nsec)
-	if err != nil {
-		return fmt.Errorf("failed to decode nsec: %w", err)
-	}
-	sk := skRaw.(string)
-
-	// Get public key
-	pubkey, err := nostr.GetPublicKey(sk)
+	// Resolve the user to the signer of its delete requests
+	signer, err := resolveSigner(args[0])
 	if err != nil {
-		return fmt.Errorf("failed to get public key: %w", err)
+		return err
 	}
+	pubkey := signer.PublicKey()
 
 	// Get relay URL
-	relayURL := k.String("relay")
-	if relayURL == "" {
-		return fmt.Errorf("relay URL is required (--relay)")
+	relayURL, err := primaryRelayURL()
+	if err != nil {
+		return err
 	}
 
 	// Get flags
@@ -203,7 +171,7 @@ func runReset(cmd *cobra.Command, args []string) error {
 			}
 
 			// Sign the delete event
-			if err := deleteEvent.Sign(sk); err != nil {
+			if err := signer.SignEvent(ctx, deleteEvent); err != nil {
 				if len(eventsToDelete) < 20 {
 					fmt.Printf("Failed to sign delete event for %s: %v\n", eventToDelete.ID, err)
 				}
@@ -38,13 +38,16 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	
 	// Global flags
-	rootCmd.PersistentFlags().String("relay", "wss://relay.damus.io", "Nostr relay URL")
+	rootCmd.PersistentFlags().StringSlice("relay", []string{"wss://relay.damus.io"}, "Nostr relay URL (repeat or comma-separate for multiple relays)")
+	rootCmd.PersistentFlags().Int("quorum", 1, "Number of relays that must accept a published event (0 = all)")
 }
 
 func initConfig() {
 	// Load defaults from flag definitions
 	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
-		if f.Value.String() != "" {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			k.Set(normalizeKey(f.Name), sv.GetSlice())
+		} else if f.Value.String() != "" {
 			k.Set(normalizeKey(f.Name), f.Value.String())
 		}
 	})
@@ -59,9 +62,13 @@ func initConfig() {
 	loadEnvFile()
 
 	// Load environment variables (highest priority)
-	k.Load(env.Provider("NOLOC_", ".", func(s string) string {
-		return strings.ReplaceAll(strings.ToLower(s), "_", ".")
-	}), nil)
+	k.Load(env.Provider("NOLOC_", ".", envKey), nil)
+}
+
+// envKey turns a NOLOC_ variable name into a config key, e.g.
+// NOLOC_UPDATE_INTERVAL into update.interval
+func envKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, "NOLOC_")), "_", ".")
 }
 
 // loadEnvFile loads NOLOC_ prefixed variables from .env file
@@ -77,54 +84,65 @@ func loadEnvFile() {
 
 	for _, key := range tempK.Keys() {
 		if strings.HasPrefix(key, "NOLOC_") {
-			normalizedKey := strings.ToLower(strings.TrimPrefix(key, "NOLOC_"))
-			normalizedKey = strings.ReplaceAll(normalizedKey, "_", ".")
-			k.Set(normalizedKey, tempK.Get(key))
+			k.Set(envKey(key), tempK.Get(key))
 		}
 	}
 }
 
-// LoadFlags merges command flags into config and resolves identity references
+// LoadFlags merges command flags into config
 func LoadFlags(cmd *cobra.Command) {
 	// Set defaults from command flags
 	cmd.Flags().VisitAll(func(f *pflag.Flag) {
 		if k.Get(normalizeKey(f.Name)) == nil {
-			k.Set(normalizeKey(f.Name), f.DefValue)
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				k.Set(normalizeKey(f.Name), sv.GetSlice())
+			} else {
+				k.Set(normalizeKey(f.Name), f.DefValue)
+			}
 		}
 	})
 	
 	// Override with explicitly set flags
 	cmd.Flags().Visit(func(f *pflag.Flag) {
-		value := f.Value.String()
-		// Resolve identity references for specific flags
-		if f.Name == "sender" {
-			// For iss command, sender is nsec
-			if resolved, err := ResolveIdentityReference(value, "nsec"); err == nil {
-				value = resolved
-			}
-		} else if f.Name == "receiver" {
-			// Determine if this is for iss (npub) or listen (nsec) command
-			if cmd.Name() == "iss" {
-				if resolved, err := ResolveIdentityReference(value, "npub"); err == nil {
-					value = resolved
-				}
-			} else if cmd.Name() == "listen" {
-				if resolved, err := ResolveIdentityReference(value, "nsec"); err == nil {
-					value = resolved
-				}
-			}
+		// List flags are stored as string slices
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			k.Set(normalizeKey(f.Name), sv.GetSlice())
+			return
 		}
-		k.Set(normalizeKey(f.Name), value)
+
+		// Identity references are resolved by the commands
+		k.Set(normalizeKey(f.Name), f.Value.String())
 	})
 	
 	// Override with changed persistent flags
 	cmd.PersistentFlags().Visit(func(f *pflag.Flag) {
-		if f.Changed {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			k.Set(normalizeKey(f.Name), sv.GetSlice())
+		} else if f.Changed {
 			k.Set(normalizeKey(f.Name), f.Value.String())
 		}
 	})
 }
 
+// stringList returns a list config value. Values set in the environment or
+// as a YAML scalar are plain strings and are split on commas.
+func stringList(key string) []string {
+	values := k.Strings(key)
+	if s, ok := k.Get(key).(string); ok && len(values) == 0 && s != "" {
+		values = []string{s}
+	}
+
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
 // normalizeKey converts flag names to config keys (sender-nsec -> sender.nsec)
 func normalizeKey(name string) string {
 	return strings.ReplaceAll(name, "-", ".")
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// testConfig gives a test an empty config, identity store and prompt input
+func testConfig(t *testing.T, input string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	oldK, oldPassphrase, oldInput := k, storePassphrase, promptInput
+	k = koanf.New(".")
+	storePassphrase = ""
+	promptInput = strings.NewReader(input)
+	t.Cleanup(func() {
+		k, storePassphrase, promptInput = oldK, oldPassphrase, oldInput
+	})
+}
+
+// testIdentity generates an identity with a plaintext nsec
+func testIdentity(name string) Identity {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	nsec, _ := nip19.EncodePrivateKey(sk)
+	npub, _ := nip19.EncodePublicKey(pk)
+	return Identity{Name: name, Nsec: nsec, Npub: npub, Hex: pk}
+}
+
+// readStoreFile returns the raw identity file
+func readStoreFile(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(getIdentityFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// writeConfig writes ~/.noloc.yaml and loads it as the command line does
+func writeConfig(t *testing.T, config string) {
+	t.Helper()
+	home, _ := os.UserHomeDir()
+	if err := os.WriteFile(filepath.Join(home, ".noloc.yaml"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	initConfig()
+}
+
+// captureOutput returns what the function prints to stdout
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
@@ -4,129 +4,218 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"strconv"
+	"strings"
 	"time"
 
-	"github.com/mmcloughlin/geohash"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
-	"github.com/nbd-wtf/go-nostr/nip44"
 	"github.com/spf13/cobra"
+
+	"noloc/location"
 )
 
 var sendCmd = &cobra.Command{
-	Use:   "send <geohash>",
-	Short: "Send an encrypted location message",
-	Long:  "Send an encrypted location message to a receiver via a Nostr relay",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runSend,
+	Use:   "send <location>",
+	Short: "Send an encrypted or public location message",
+	Long: `Send an encrypted location message (kind 30473) to one or more receivers
+via a Nostr relay, or with --public a public location event (kind 30472) that anyone
+can read. --title, --summary, --hashtag, --image and --location add the
+optional NIP-24/NIP-52 tags in both modes.
+
+Each receiver gets its own event encrypted with its own NIP-44 conversation
+key. With several receivers the d-tag is derived per receiver so the events
+do not replace each other.
+
+The location is a geohash or coordinates in one of these forms:
+  60.1699,24.9384              decimal latitude,longitude
+  +60.1699+024.9384/           ISO 6709 (degrees, minutes and seconds forms too)
+  geo:60.1699,24.9384;u=35     geo URI, u sets the accuracy unless --accuracy is given
+
+Coordinates are encoded with --precision characters, or with the shortest
+precision whose cell fits within --accuracy, or with all 12 characters.
+A geohash is cut to --precision.
+
+Privacy policies in ~/.noloc.yaml (privacy.default, privacy.<sender> and
+privacy.<receiver>) fuzz, snap and coarsen the position before it is
+encoded, and raise the accuracy tag by the noise they add. Each receiver
+gets the strictest combination of the policies that apply to it. Inside a
+private zone of the sender (zones.<sender>) the position is not sent, or
+only as the coarse geohash of the zone or a placeholder. --dry-run shows
+what each receiver would get.
+
+With --wrap each event is hidden in a NIP-59 gift wrap (kind 1059), signed
+by a random key with a jittered timestamp, so relays see neither the sender
+nor the d-tag nor when the location was sent.
+
+With --ephemeral @seed the event is signed with a key derived from a seed
+shared with the receiver (see 'noloc id seed') instead of --sender, so
+locations sent in different rotation epochs cannot be linked.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSend,
 }
 
 func init() {
 	rootCmd.AddCommand(sendCmd)
 
 	// Required flags
-	sendCmd.Flags().String("sender", "", "Sender identity (@name) or nsec")
-	sendCmd.Flags().String("receiver", "", "Receiver npub or @name")
+	sendCmd.Flags().String("sender", "", "Sender identity (@name), nsec or bunker:// URI, required unless --ephemeral")
+	sendCmd.Flags().String("ephemeral", "", "Sign with the current key derived from this seed (@name) instead of --sender")
+	sendCmd.Flags().StringSlice("receiver", nil, "Receiver npub, nprofile, hex, user@domain or @name (repeat or comma-separate for several), required unless --public")
 
 	// Optional flags with defaults
 	sendCmd.Flags().Int("accuracy", 0, "Accuracy radius in meters (optional)")
 	sendCmd.Flags().Int("precision", 0, "Geohash precision override (optional)")
 	sendCmd.Flags().Bool("anon", false, "Send as anonymous message (omit p-tag)")
-	sendCmd.Flags().String("name", "", "Name for the location (added to encrypted content)")
+	sendCmd.Flags().Bool("wrap", false, "Hide the sender in a NIP-59 gift wrap (kind 1059)")
+	sendCmd.Flags().Bool("dry-run", false, "Show the location each receiver would get without publishing")
+	sendCmd.Flags().String("name", "", "Name for the location (name tag, also derives the d-tag)")
 	sendCmd.Flags().Int("ttl", 3600, "Time to live in seconds (default 1 hour)")
 
-	// Mark required flags
-	sendCmd.MarkFlagRequired("sender")
-	sendCmd.MarkFlagRequired("receiver")
+	// Public event mode and optional location tags
+	sendCmd.Flags().Bool("public", false, "Send a public location event (kind 30472) instead of an encrypted one")
+	sendCmd.Flags().String("title", "", "Title of the location (NIP-24 title tag)")
+	sendCmd.Flags().String("summary", "", "Short description of the location (NIP-52 summary tag)")
+	sendCmd.Flags().StringSlice("hashtag", nil, "Hashtags for the location (NIP-24 t tags)")
+	sendCmd.Flags().String("image", "", "Image URL of the location (NIP-52 image tag)")
+	sendCmd.Flags().String("location", "", "Address or place description (NIP-52 location tag)")
 }
 
 func runSend(cmd *cobra.Command, args []string) error {
 	LoadFlags(cmd)
 
-	geohashInput := args[0]
+	locationInput := args[0]
 
 	// Get and validate sender
 	senderInput := k.String("sender")
-	if senderInput == "" {
-		return fmt.Errorf("sender is required (--sender)")
-	}
-
-	// Resolve sender identity to nsec
-	senderNsec, err := ResolveIdentityReference(senderInput, "nsec")
-	if err != nil {
-		return fmt.Errorf("failed to resolve sender: %w", err)
+	ephemeralInput := k.String("ephemeral")
+	switch {
+	case senderInput == "" && ephemeralInput == "":
+		return fmt.Errorf("sender is required (--sender or --ephemeral)")
+	case senderInput != "" && ephemeralInput != "":
+		return fmt.Errorf("--sender and --ephemeral cannot be used together")
 	}
 
-	// Decode sender nsec to get private key
-	_, skRaw, err := nip19.Decode(senderNsec)
-	if err != nil {
-		return fmt.Errorf("failed to decode sender nsec: %w", err)
-	}
-	senderSK := skRaw.(string)
-	senderPubkey, err := nostr.GetPublicKey(senderSK)
-	if err != nil {
-		return fmt.Errorf("failed to get sender public key: %w", err)
-	}
-
-	// Get and validate receiver
-	receiverInput := k.String("receiver")
-	if receiverInput == "" {
-		return fmt.Errorf("receiver is required (--receiver)")
+	// Resolve sender identity to a local key or remote signer, or derive
+	// the key of the current epoch from an ephemeral seed
+	var signer Signer
+	var senderName string // Whose private zones and privacy policy apply
+	var err error
+	if ephemeralInput != "" {
+		seed, err := resolveSeed(ephemeralInput)
+		if err != nil {
+			return err
+		}
+		if senderName, err = seed.senderName(); err != nil {
+			return err
+		}
+		signer, err = seed.signer(time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to derive ephemeral key: %w", err)
+		}
+	} else {
+		signer, err = resolveSigner(senderInput)
+		if err != nil {
+			return fmt.Errorf("failed to resolve sender: %w", err)
+		}
+		senderName = identityName(signer.PublicKey())
 	}
-
-	// Resolve receiver identity to npub
-	receiverNpub, err := ResolveIdentityReference(receiverInput, "npub")
-	if err != nil {
-		return fmt.Errorf("failed to resolve receiver: %w", err)
+	ctx := context.Background()
+	senderPubkey := signer.PublicKey()
+
+	// Get and validate receivers, public events have none
+	public := k.Bool("public")
+	receiverInputs := stringList("receiver")
+	var recipients []recipient
+	switch {
+	case public && (len(receiverInputs) > 0 || k.Bool("anon") || k.Bool("wrap")):
+		return fmt.Errorf("--receiver, --anon and --wrap cannot be used with --public")
+
+	case k.Bool("anon") && k.Bool("wrap"):
+		return fmt.Errorf("--anon cannot be used with --wrap: a gift wrap is addressed to its receiver by p-tag")
+
+	case !public:
+		if len(receiverInputs) == 0 {
+			return fmt.Errorf("receiver is required (--receiver) unless --public is set")
+		}
+		recipients, err = resolveRecipients(receiverInputs)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Decode receiver npub to get public key
-	_, pubkeyRaw, err := nip19.Decode(receiverNpub)
+	// Get relay URLs
+	relayURLs, err := relayURLs()
 	if err != nil {
-		return fmt.Errorf("failed to decode receiver npub: %w", err)
-	}
-	receiverPubkey := pubkeyRaw.(string)
-
-	// Get relay URL
-	relayURL := k.String("relay")
-	if relayURL == "" {
-		return fmt.Errorf("relay URL is required (--relay)")
+		return err
 	}
 
 	// Get optional parameters
 	accuracy := k.Int("accuracy")
 	precision := k.Int("precision")
 	anon := k.Bool("anon")
+	wrap := k.Bool("wrap")
 	locationName := k.String("name")
 	ttl := k.Int("ttl")
 
-	// Validate and potentially modify geohash based on precision
-	if precision > 0 && precision < len(geohashInput) {
-		geohashInput = geohashInput[:precision]
+	if precision < 0 || precision > 12 {
+		return fmt.Errorf("precision must be between 1 and 12 characters")
 	}
 
-	// Decode geohash to verify it's valid
-	lat, lon := geohash.Decode(geohashInput)
-	// Validate decoded values aren't zero (which would indicate invalid geohash)
-	if lat == 0 && lon == 0 && geohashInput != "s00000000000" {
-		return fmt.Errorf("invalid geohash: %s", geohashInput)
+	lat, lon, locAccuracy, precision, err := resolvePosition(locationInput, precision, float64(accuracy))
+	if err != nil {
+		return err
 	}
 
 	// Create location data
-	locationData := [][]interface{}{
-		{"g", geohashInput},
+	loc := &location.Location{
+		Title:    k.String("title"),
+		Summary:  k.String("summary"),
+		Hashtags: k.Strings("hashtag"),
+	}
+	optional := []struct{ key, value string }{
+		{"name", locationName},
+		{"image", k.String("image")},
+		{"location", k.String("location")},
+	}
+	for _, tag := range optional {
+		if tag.value != "" {
+			loc.Extra = append(loc.Extra, nostr.Tag{tag.key, tag.value})
+		}
+	}
+
+	// Private zones of the sender and privacy policies of the sender and
+	// each receiver apply before encoding, all with the same random offset
+	zones, err := privateZones(senderName)
+	if err != nil {
+		return err
+	}
+	offset := location.RandomOffset()
+	protect := func(policy location.Policy) (*location.Location, *location.Zone, bool) {
+		protected := *loc
+		zone, ok := location.Protect(&protected, zones, policy, lat, lon, locAccuracy, precision, offset)
+		return &protected, zone, ok
 	}
 
-	// Add optional fields
-	if accuracy > 0 {
-		locationData = append(locationData, []interface{}{"accuracy", strconv.Itoa(accuracy)})
+	if k.Bool("dry.run") {
+		fmt.Println("Dry run, nothing is published:")
+		if public {
+			policy := privacyPolicy(senderName)
+			publicLoc, zone, ok := protect(policy)
+			printDryRun("public", publicLoc, zone, ok, policy)
+		}
+		for _, r := range recipients {
+			policy := privacyPolicy(senderName, r.name)
+			recipientLoc, zone, ok := protect(policy)
+			printDryRun(r.label, recipientLoc, zone, ok, policy)
+		}
+		return nil
 	}
 
-	if locationName != "" {
-		locationData = append(locationData, []interface{}{"name", locationName})
+	// Zones are the same for every receiver
+	if zone := location.FindZone(zones, lat, lon); zone != nil && zone.Action == location.ZoneSkip {
+		fmt.Printf("Location not sent: %s\n", describeZone(zone))
+		return nil
 	}
 
 	// Determine d-tag
@@ -142,79 +231,209 @@ func runSend(cmd *cobra.Command, args []string) error {
 		dTag = nostr.GeneratePrivateKey()[:8] // Use first 8 chars of random key
 	}
 
-	// Encrypt location data
-	locationJSON, err := json.Marshal(locationData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal location data: %w", err)
+	opts := location.EventOptions{
+		D:    dTag,
+		TTL:  time.Duration(ttl) * time.Second,
+		Anon: anon,
 	}
 
-	conversationKey, err := nip44.GenerateConversationKey(receiverPubkey, senderSK)
-	if err != nil {
-		return fmt.Errorf("failed to generate conversation key: %w", err)
+	defer pool.close()
+
+	if public {
+		policy := privacyPolicy(senderName)
+		publicLoc, zone, _ := protect(policy)
+		event, err := location.SignPublicEvent(ctx, signer, publicLoc, opts)
+		if err != nil {
+			return err
+		}
+
+		// Publish to all relays
+		results, err := publishToRelays(relayURLs, event)
+		for _, r := range results {
+			status := "✓"
+			if r.err != nil {
+				status = "✗"
+			}
+			fmt.Printf("%s %s: %s\n", status, r.relayURL, r.message())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to publish event: %w", err)
+		}
+
+		fmt.Printf("Location message sent successfully!\n")
+		printSendSummary(publicLoc.Geohash, locationName, dTag, event)
+		if zone != nil {
+			fmt.Printf("  Privacy: %s\n", describeZone(zone))
+		} else if policy.Active() {
+			fmt.Printf("  Privacy: %s\n", policy)
+		}
+		fmt.Printf("  Mode: Public (kind 30472)\n")
+		fmt.Printf("  Relays: %d/%d accepted\n", countAccepted(results), len(results))
+		fmt.Printf("  Event ID: %s\n", event.ID)
+		return nil
 	}
 
-	encryptedContent, err := nip44.Encrypt(string(locationJSON), conversationKey)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt content: %w", err)
+	// One event per recipient, each encrypted with its own conversation key
+	delivered := 0
+	var lastEvent *nostr.Event
+	geohashes := make(map[string]bool)
+	for _, r := range recipients {
+		recipientOpts := opts
+		if len(recipients) > 1 {
+			// Events of one sender with the same d-tag would replace each other
+			recipientOpts.D = recipientDTag(dTag, r.pubkey)
+		}
+
+		recipientLoc, _, _ := protect(privacyPolicy(senderName, r.name))
+		geohashes[recipientLoc.Geohash] = true
+
+		var event *nostr.Event
+		if wrap {
+			event, err = location.WrapPrivateEvent(ctx, signer, r.pubkey, recipientLoc, recipientOpts)
+		} else {
+			event, err = location.SignPrivateEvent(ctx, signer, r.pubkey, recipientLoc, recipientOpts)
+		}
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", r.label, err)
+			continue
+		}
+		lastEvent = event
+
+		results, err := publishToRelays(withRelayHints(relayURLs, r.relays), event)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", r.label, err)
+		} else {
+			delivered++
+			fmt.Printf("✓ %s: %d/%d relays accepted (event %s)\n", r.label, countAccepted(results), len(results), event.ID)
+		}
+		for _, result := range results {
+			if result.err != nil {
+				fmt.Printf("    ✗ %s: %s\n", result.relayURL, result.message())
+			}
+		}
 	}
 
-	// Build event tags
-	expiration := time.Now().Add(time.Duration(ttl) * time.Second).Unix()
-
-	tags := nostr.Tags{
-		{"d", dTag},
-		{"expiration", fmt.Sprintf("%d", expiration)},
+	if delivered == 0 {
+		return fmt.Errorf("failed to deliver location to any of %d recipients", len(recipients))
 	}
 
-	// Only add p-tag if not anonymous
-	if !anon {
-		tags = append(nostr.Tags{{"p", receiverPubkey}}, tags...)
+	if len(recipients) > 1 {
+		dTag = "derived per recipient"
 	}
-
-	// Create the event
-	event := &nostr.Event{
-		PubKey:    senderPubkey,
-		CreatedAt: nostr.Timestamp(time.Now().Unix()),
-		Kind:      30473,
-		Tags:      tags,
-		Content:   encryptedContent,
+	var geohashSummary string
+	for gh := range geohashes {
+		geohashSummary = gh
+	}
+	if len(geohashes) > 1 {
+		geohashSummary = "per recipient privacy policy"
+	}
+	fmt.Printf("Location message sent successfully!\n")
+	printSendSummary(geohashSummary, locationName, dTag, lastEvent)
+	if anon {
+		fmt.Printf("  Mode: Anonymous (no p-tag)\n")
+	} else if wrap {
+		fmt.Printf("  Mode: Gift wrapped (NIP-59)\n")
+	} else {
+		fmt.Printf("  Mode: Direct message\n")
+	}
+	if ephemeralInput != "" {
+		fmt.Printf("  Sender: ephemeral key of %s\n", ephemeralInput)
+	}
+	if len(recipients) == 1 {
+		fmt.Printf("  Receiver: %s\n", recipients[0].npub)
+		fmt.Printf("  Event ID: %s\n", lastEvent.ID)
+	} else {
+		fmt.Printf("  Recipients: %d/%d delivered\n", delivered, len(recipients))
 	}
 
-	// Sign the event
-	if err := event.Sign(senderSK); err != nil {
-		return fmt.Errorf("failed to sign event: %w", err)
+	if delivered < len(recipients) {
+		return fmt.Errorf("failed to deliver location to %d of %d recipients", len(recipients)-delivered, len(recipients))
 	}
+	return nil
+}
 
-	// Connect to relay and publish
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// recipient is a resolved receiver of an encrypted location
+type recipient struct {
+	label  string // Receiver as given on the command line
+	name   string // Identity or contact name, "" when not stored
+	npub   string
+	pubkey string
+	relays []string // Relay hints, published to in addition to --relay
+}
 
-	relay, err := nostr.RelayConnect(ctx, relayURL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to relay: %w", err)
+// resolveRecipients resolves receiver references to public keys, dropping
+// duplicates
+func resolveRecipients(inputs []string) ([]recipient, error) {
+	var recipients []recipient
+	seen := make(map[string]bool)
+	for _, input := range inputs {
+		pubkey, relays, err := resolveProfile(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve receiver %s: %w", input, err)
+		}
+		if seen[pubkey] {
+			continue
+		}
+		seen[pubkey] = true
+
+		npub, err := nip19.EncodePublicKey(pubkey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode receiver npub: %w", err)
+		}
+		recipients = append(recipients, recipient{label: input, name: identityName(pubkey), npub: npub, pubkey: pubkey, relays: relays})
 	}
-	defer relay.Close()
+	return recipients, nil
+}
 
-	if err := relay.Publish(ctx, *event); err != nil {
-		return fmt.Errorf("failed to publish event: %w", err)
-	}
+// recipientDTag derives a per-recipient d-tag. It is hashed so that the d-tag
+// of an anonymous event does not reveal its receiver.
+func recipientDTag(dTag, receiverPubkey string) string {
+	h := sha256.New()
+	h.Write([]byte(dTag))
+	h.Write([]byte(receiverPubkey))
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
 
-	// Print confirmation
-	fmt.Printf("Location message sent successfully!\n")
+// printSendSummary prints the location fields shared by all sent events
+func printSendSummary(geohashInput, locationName, dTag string, event *nostr.Event) {
 	fmt.Printf("  Geohash: %s\n", geohashInput)
 	if locationName != "" {
 		fmt.Printf("  Name: %s\n", locationName)
 	}
 	fmt.Printf("  D-tag: %s\n", dTag)
-	fmt.Printf("  Receiver: %s\n", receiverNpub)
-	if anon {
-		fmt.Printf("  Mode: Anonymous (no p-tag)\n")
-	} else {
-		fmt.Printf("  Mode: Direct message\n")
+	if expiration := location.Expiration(event); !expiration.IsZero() {
+		fmt.Printf("  Expires: %s\n", expiration.Format(time.RFC3339))
 	}
-	fmt.Printf("  Relay: %s\n", relayURL)
-	fmt.Printf("  Event ID: %s\n", event.ID)
-	fmt.Printf("  Expires: %s\n", time.Unix(expiration, 0).Format(time.RFC3339))
+}
 
-	return nil
-}
\ No newline at end of file
+// resolvePosition turns the location argument into coordinates and returns
+// them with the accuracy to publish and the precision to encode them with.
+// A geohash becomes the center of its cell, encoded with its own length.
+// Anything containing characters outside the geohash alphabet is parsed as
+// coordinates.
+func resolvePosition(input string, precision int, accuracy float64) (float64, float64, float64, int, error) {
+	if !strings.ContainsAny(input, ",:+-.") {
+		gh := strings.ToLower(input)
+		if !location.ValidGeohash(gh) {
+			return 0, 0, 0, 0, fmt.Errorf("invalid geohash %q: must be 1-12 characters of 0-9 and b-z without a, i, l, o", input)
+		}
+		if precision > 0 && precision < len(gh) {
+			gh = gh[:precision]
+		}
+		lat, lon := location.GeohashBox(gh).Center()
+		return lat, lon, accuracy, len(gh), nil
+	}
+
+	lat, lon, uncertainty, err := location.ParseCoordinates(input)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if accuracy == 0 {
+		accuracy = uncertainty
+	}
+	if precision == 0 && accuracy > 0 {
+		precision = location.PrecisionForAccuracy(lat, lon, accuracy)
+	}
+
+	return lat, lon, accuracy, precision, nil
+}
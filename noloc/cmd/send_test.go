@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"noloc/location"
+)
+
+func TestSendPublic(t *testing.T) {
+	testConfig(t, "")
+	relay, url := newTestRelay(t)
+	sender := testIdentity("alice")
+	k.Set("relay", url)
+	k.Set("sender", sender.Nsec)
+	k.Set("public", true)
+	k.Set("title", "Market square")
+	k.Set("hashtag", []string{"helsinki"})
+
+	captureOutput(t, func() {
+		if err := runSend(sendCmd, []string{"60.1699,24.9384"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	events := relay.stored()
+	if len(events) != 1 {
+		t.Fatalf("%d events published, want 1", len(events))
+	}
+	event := events[0]
+	if event.Kind != location.KindPublic || event.Content != "" || event.PubKey != sender.Hex {
+		t.Errorf("event kind %d by %s with content %q", event.Kind, event.PubKey, event.Content)
+	}
+	if event.Tags.Find("p") != nil {
+		t.Error("public event has a p-tag")
+	}
+	loc, err := location.ParsePublicEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc.Geohash != location.Encode(60.1699, 24.9384, 0) || loc.Title != "Market square" || len(loc.Hashtags) != 1 {
+		t.Errorf("location = %+v", loc)
+	}
+}
+
+func TestRecipientDTag(t *testing.T) {
+	bob, carol := testIdentity("bob").Hex, testIdentity("carol").Hex
+
+	d := recipientDTag("home", bob)
+	if len(d) != 8 || d == "home" {
+		t.Errorf("d-tag = %q, want 8 hex characters", d)
+	}
+	if recipientDTag("home", bob) != d {
+		t.Error("d-tag is not stable")
+	}
+	if recipientDTag("home", carol) == d {
+		t.Error("receivers share a d-tag")
+	}
+	if recipientDTag("office", bob) == d {
+		t.Error("locations share a d-tag")
+	}
+}
+
+func TestSendMultipleRecipients(t *testing.T) {
+	testConfig(t, "")
+	relay, url := newTestRelay(t)
+	sender := testIdentity("alice")
+	receivers := map[string]Identity{}
+	for _, name := range []string{"bob", "carol"} {
+		receivers[name] = testIdentity(name)
+	}
+	k.Set("relay", url)
+	k.Set("sender", sender.Nsec)
+	k.Set("receiver", []string{receivers["bob"].Npub, receivers["carol"].Npub})
+	k.Set("name", "home")
+
+	// Sending the same named location again replaces the events
+	for range 2 {
+		captureOutput(t, func() {
+			if err := runSend(sendCmd, []string{"60.1699,24.9384"}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	events := relay.stored()
+	if len(events) != 4 {
+		t.Fatalf("%d events published, want 2 per receiver", len(events))
+	}
+	dTags := make(map[string]string) // Receiver pubkey by d-tag
+	for _, event := range events {
+		if event.Kind != location.KindPrivate || event.Tags.Find("g") != nil {
+			t.Errorf("event kind %d with tags %v", event.Kind, event.Tags)
+		}
+		p := event.Tags.Find("p")
+		if p == nil {
+			t.Fatal("event without p-tag")
+		}
+		d := event.Tags.GetD()
+		if other, ok := dTags[d]; ok && other != p[1] {
+			t.Errorf("d-tag %s shared by two receivers", d)
+		}
+		dTags[d] = p[1]
+	}
+	if len(dTags) != 2 {
+		t.Errorf("d-tags %v, want one stable d-tag per receiver", dTags)
+	}
+
+	for _, receiver := range receivers {
+		_, sk, _ := nip19.Decode(receiver.Nsec)
+		decrypted := 0
+		for _, event := range events {
+			if event.Tags.Find("p")[1] != receiver.Hex {
+				continue
+			}
+			if _, err := location.ParsePrivateEvent(event, sk.(string)); err != nil {
+				t.Errorf("%s: %v", receiver.Name, err)
+			}
+			decrypted++
+		}
+		if decrypted != 2 {
+			t.Errorf("%s got %d events, want 2", receiver.Name, decrypted)
+		}
+	}
+}
+
+func TestSendEphemeralOwnerZones(t *testing.T) {
+	testConfig(t, "")
+	relay, url := newTestRelay(t)
+	bob := testIdentity("bob")
+	err := saveIdentities(map[string]Identity{
+		"alice":   testIdentity("alice"),
+		"courier": {Name: "courier", Seed: testSeed, Rotation: "1h0m0s", Owner: "alice"},
+		"orphan":  {Name: "orphan", Seed: testSeed, Rotation: "1h0m0s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, `
+zones:
+  alice:
+    - name: home
+      center: 60.1699,24.9384
+      radius: 300
+`)
+	k.Set("relay", url)
+	k.Set("receiver", bob.Npub)
+
+	// The zone of the owner applies, not one under the seed name
+	k.Set("ephemeral", "@courier")
+	output := captureOutput(t, func() {
+		if err := runSend(sendCmd, []string{"60.1700,24.9385"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(output, "inside private zone home, not published") {
+		t.Errorf("output = %q, want the position suppressed", output)
+	}
+	if events := relay.stored(); len(events) != 0 {
+		t.Errorf("%d events published inside the owner's zone", len(events))
+	}
+
+	// A seed without an owner would publish without any zone
+	k.Set("ephemeral", "@orphan")
+	if err := runSend(sendCmd, []string{"60.1700,24.9385"}); err == nil || !strings.Contains(err.Error(), "no owner") {
+		t.Errorf("err = %v, want a seed without owner refused", err)
+	}
+}
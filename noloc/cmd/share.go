@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/spf13/cobra"
+
+	"noloc/location"
+)
+
+// defaultShareTTL is how long shared positions stay valid by default, in
+// seconds like the ttl of send
+const defaultShareTTL = 600
+
+var shareCmd = &cobra.Command{
+	Use:   "share <source>",
+	Short: "Continuously share positions from a position source",
+	Long: `Share the positions of a source as they come in, as public location
+events (kind 30472) with --public or encrypted ones (kind 30473) to each
+--receiver.
+
+Sources:
+  gpsd      fixes of a GPS receiver from gpsd at --gpsd-address
+  iss       International Space Station, polled every --interval
+  random    --count randomly moving walkers, moved every --interval
+
+Each moving object of a source is published under its own d-tag, so its
+events replace each other. --min-interval limits how often an object is
+published, and --min-distance skips positions less than that many meters
+from the last published one until --max-interval (half of --ttl by
+default) has passed, so that the location of a resting object does not
+expire. Inside a private zone only the zone's cell is shared, without
+extra tags such as the altitude and speed reported by gpsd.
+
+--ephemeral, --anon, --wrap, privacy policies and private zones work as in
+send. --dry-run logs what would be published instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShare,
+}
+
+func init() {
+	rootCmd.AddCommand(shareCmd)
+
+	shareCmd.Flags().String("sender", "", "Sender identity (@name), nsec or bunker:// URI, required unless --ephemeral")
+	shareCmd.Flags().String("ephemeral", "", "Sign with keys derived from this seed (@name) instead of --sender")
+	shareCmd.Flags().Bool("per-session", false, "With --ephemeral, keep one key for the whole session instead of rotating")
+	shareCmd.Flags().StringSlice("receiver", nil, "Receiver npub, nprofile, hex, user@domain or @name (repeat or comma-separate for several), required unless --public")
+	shareCmd.Flags().Bool("public", false, "Share public location events (kind 30472) instead of encrypted ones")
+	shareCmd.Flags().Bool("anon", false, "Send anonymous locations (omit p-tag)")
+	shareCmd.Flags().Bool("wrap", false, "Hide the sender in NIP-59 gift wraps (kind 1059)")
+	shareCmd.Flags().Bool("dry-run", false, "Log the location of each update without publishing")
+
+	shareCmd.Flags().Int("accuracy", 0, "Accuracy in meters of sources that report none")
+	shareCmd.Flags().Int("precision", 0, "Geohash precision (number of characters, 1-12)")
+	shareCmd.Flags().Int("ttl", defaultShareTTL, "Time to live of each shared location in seconds")
+	shareCmd.Flags().Duration("min-interval", 0, "Publish each object at most this often")
+	shareCmd.Flags().Float64("min-distance", 0, "Skip positions closer than this many meters to the last published one")
+	shareCmd.Flags().Duration("max-interval", 0, "With --min-distance, publish at least this often (default half of --ttl)")
+
+	// Options of the sources
+	shareCmd.Flags().IntP("interval", "i", defaultInterval, "Polling interval in seconds (iss, random)")
+	shareCmd.Flags().IntP("count", "c", 3, "Number of moving objects (random)")
+	shareCmd.Flags().String("identifier", "walker", "Base name of the moving objects (random)")
+	shareCmd.Flags().String("gpsd-address", defaultGPSDAddress, "Host and port of gpsd (gpsd)")
+	shareCmd.Flags().String("gpsd-device", "", "Only share fixes of this device, such as /dev/ttyUSB0 (gpsd)")
+}
+
+func runShare(cmd *cobra.Command, args []string) error {
+	LoadFlags(cmd)
+
+	newSource, err := positionSource(args[0])
+	if err != nil {
+		return err
+	}
+
+	public := k.Bool("public")
+	if public && (len(stringList("receiver")) > 0 || k.Bool("anon") || k.Bool("wrap")) {
+		return fmt.Errorf("--receiver, --anon and --wrap cannot be used with --public")
+	}
+	config, err := loadShareConfig(public)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Sharing positions from %s...", args[0])
+	config.logMode()
+
+	source, err := newSource()
+	if err != nil {
+		return err
+	}
+	return share(source, config)
+}
+
+// shareConfig holds who positions are shared with and how
+type shareConfig struct {
+	signer      Signer
+	ephemeral   *ephemeralSender // Replaces signer when set
+	targets     []shareTarget
+	relayURLs   []string
+	anon        bool
+	wrap        bool
+	dryRun      bool
+	accuracy    float64 // Accuracy of positions without one
+	precision   int
+	ttl         time.Duration
+	minInterval time.Duration
+	minDistance float64
+	maxInterval time.Duration
+}
+
+// shareTarget is the public or one receiver of shared positions
+type shareTarget struct {
+	recipient *recipient // nil for public events
+	guard     *positionGuard
+}
+
+// label names the target in logs
+func (t shareTarget) label() string {
+	if t.recipient == nil {
+		return "public"
+	}
+	return t.recipient.label
+}
+
+// loadShareConfig reads the sender, receivers and options shared by the
+// commands that share positions continuously. Public configs have no
+// receivers.
+func loadShareConfig(public bool) (*shareConfig, error) {
+	sender := k.String("sender")
+	ephemeral := k.String("ephemeral")
+	switch {
+	case sender == "" && ephemeral == "":
+		return nil, fmt.Errorf("sender is required (--sender, -s or --ephemeral)")
+	case sender != "" && ephemeral != "":
+		return nil, fmt.Errorf("--sender and --ephemeral cannot be used together")
+	case k.Bool("per.session") && ephemeral == "":
+		return nil, fmt.Errorf("--per-session requires --ephemeral")
+	}
+
+	relayURLs, err := relayURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &shareConfig{
+		relayURLs:   relayURLs,
+		anon:        k.Bool("anon"),
+		wrap:        k.Bool("wrap"),
+		dryRun:      k.Bool("dry.run"),
+		accuracy:    float64(k.Int("accuracy")),
+		precision:   k.Int("precision"),
+		ttl:         time.Duration(k.Int("ttl")) * time.Second,
+		minInterval: k.Duration("min.interval"),
+		minDistance: k.Float64("min.distance"),
+		maxInterval: k.Duration("max.interval"),
+	}
+	if config.anon && config.wrap {
+		return nil, fmt.Errorf("--anon cannot be used with --wrap: a gift wrap is addressed to its receiver by p-tag")
+	}
+	if config.precision < 0 || config.precision > 12 {
+		return nil, fmt.Errorf("precision must be between 1 and 12 characters")
+	}
+	if config.minDistance < 0 {
+		return nil, fmt.Errorf("--min-distance must not be negative")
+	}
+	if config.maxInterval == 0 {
+		config.maxInterval = config.ttl / 2
+	}
+
+	var senderName string
+	if ephemeral != "" {
+		seed, err := resolveSeed(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		if senderName, err = seed.senderName(); err != nil {
+			return nil, err
+		}
+		config.ephemeral = &ephemeralSender{seed: seed, session: time.Now(), perSession: k.Bool("per.session")}
+	} else {
+		config.signer, err = resolveSigner(sender)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve sender: %w", err)
+		}
+		senderName = identityName(config.signer.PublicKey())
+	}
+
+	// Private zones of the sender and privacy policies of the sender and
+	// each receiver apply to every position
+	if public {
+		guard, err := newPositionGuard(senderName)
+		if err != nil {
+			return nil, err
+		}
+		config.targets = []shareTarget{{guard: guard}}
+		return config, nil
+	}
+
+	receivers := stringList("receiver")
+	if len(receivers) == 0 {
+		return nil, fmt.Errorf("receiver is required (--receiver)")
+	}
+	recipients, err := resolveRecipients(receivers)
+	if err != nil {
+		return nil, err
+	}
+	for i := range recipients {
+		guard, err := newPositionGuard(senderName, recipients[i].name)
+		if err != nil {
+			return nil, err
+		}
+		config.targets = append(config.targets, shareTarget{recipient: &recipients[i], guard: guard})
+	}
+	return config, nil
+}
+
+// logMode logs who positions are shared with and how
+func (c *shareConfig) logMode() {
+	switch {
+	case c.targets[0].recipient == nil:
+		log.Printf("Mode: Public broadcast (kind 30472)")
+	case c.anon:
+		log.Printf("Mode: Anonymous (no p-tag)")
+	case c.wrap:
+		log.Printf("Mode: Gift wrapped (NIP-59)")
+	default:
+		log.Printf("Mode: Direct message")
+	}
+	if len(c.targets) > 1 {
+		log.Printf("Receivers: %d", len(c.targets))
+	}
+	if c.ephemeral != nil {
+		rotation := "every " + c.ephemeral.seed.rotation.String()
+		if c.ephemeral.perSession {
+			rotation = "per session"
+		}
+		log.Printf("Sender: ephemeral keys of %s (%s)", c.ephemeral.seed.name, rotation)
+	}
+
+	if len(c.targets) == 1 {
+		c.targets[0].guard.logPrivacy()
+	} else {
+		for _, target := range c.targets {
+			if target.guard.policy.Active() {
+				log.Printf("Privacy for %s: %s", target.label(), target.guard.policy)
+			}
+		}
+		if zones := len(c.targets[0].guard.zones); zones > 0 {
+			log.Printf("Private zones: %d", zones)
+		}
+	}
+
+	if c.minInterval > 0 {
+		log.Printf("Minimum interval: %s", c.minInterval)
+	}
+	if c.minDistance > 0 {
+		log.Printf("Minimum distance: %s (republished after %s)", formatDistance(c.minDistance), c.maxInterval)
+	}
+	if c.dryRun {
+		log.Printf("Dry run, nothing is published")
+	}
+	log.Printf("Relays: %s (quorum %d)", strings.Join(c.relayURLs, ", "), publishQuorum(len(c.relayURLs)))
+}
+
+// sharedPosition is the last published position of a moving object
+type sharedPosition struct {
+	lat, lon float64
+	time     time.Time
+}
+
+// share publishes the positions of the source until it ends or the command
+// is interrupted
+func share(source PositionSource, config *shareConfig) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer pool.close()
+
+	last := make(map[string]sharedPosition)
+	for {
+		position, err := source.Next(ctx)
+		if ctx.Err() != nil {
+			log.Println("Shutting down...")
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			log.Printf("Position source ended")
+			return nil
+		}
+		if err != nil {
+			log.Printf("Error reading position: %v", err)
+			continue
+		}
+		if position.Time.IsZero() {
+			position.Time = time.Now()
+		}
+
+		if prev, ok := last[position.Track]; ok && !config.due(prev, position) {
+			continue
+		}
+		if config.publish(ctx, position) {
+			last[position.Track] = sharedPosition{lat: position.Lat, lon: position.Lon, time: position.Time}
+		}
+	}
+}
+
+// due reports whether a position is published after the last published
+// position of its object
+func (c *shareConfig) due(prev sharedPosition, p *Position) bool {
+	elapsed := p.Time.Sub(prev.time)
+	if elapsed < c.minInterval {
+		return false
+	}
+	if c.minDistance > 0 && location.Distance(prev.lat, prev.lon, p.Lat, p.Lon) < c.minDistance {
+		return c.maxInterval > 0 && elapsed >= c.maxInterval
+	}
+	return true
+}
+
+// publish shares a position with every target, all with the same random
+// offset, and reports whether any of them got it
+func (c *shareConfig) publish(ctx context.Context, p *Position) bool {
+	name := p.Details.Title
+	if name == "" {
+		name = "Position " + p.Track
+	}
+	log.Printf("%s: Lat=%.6f, Lon=%.6f", name, p.Lat, p.Lon)
+
+	signer := c.signer
+	if c.ephemeral != nil {
+		var err error
+		signer, err = c.ephemeral.signer(time.Now())
+		if err != nil {
+			log.Printf("Error deriving ephemeral key: %v", err)
+			return false
+		}
+	}
+
+	accuracy := p.Accuracy
+	if accuracy == 0 {
+		accuracy = c.accuracy
+	}
+	precision := c.precision
+	if precision == 0 && accuracy > 0 {
+		precision = location.PrecisionForAccuracy(p.Lat, p.Lon, accuracy)
+	}
+
+	offset := location.RandomOffset()
+	published := false
+	for _, target := range c.targets {
+		loc := p.Details
+		zone, err := target.guard.apply(&loc, p.Lat, p.Lon, accuracy, precision, offset)
+		if isZoneSkip(err) {
+			log.Printf("Skipping update for %s: %v", target.label(), err)
+			continue
+		}
+		if zone != nil {
+			// Altitude or speed would tell more than the coarse cell
+			loc.Extra = nil
+		}
+		if c.dryRun {
+			log.Printf("Would publish to %s: %s", target.label(), describeProtected(&loc, zone, target.guard.policy))
+			published = true
+			continue
+		}
+
+		event, err := c.event(ctx, signer, target, &loc, p)
+		if err != nil {
+			log.Printf("Error creating location event for %s: %v", target.label(), err)
+			continue
+		}
+
+		relays := c.relayURLs
+		if target.recipient != nil {
+			relays = withRelayHints(relays, target.recipient.relays)
+		}
+		results, err := publishToRelays(relays, event)
+		logPublishFailures(results)
+		if err != nil {
+			log.Printf("Error publishing to %s: %v", target.label(), err)
+			continue
+		}
+		log.Printf("Published location to %s (ID: %s, %d/%d relays)", target.label(), event.ID, countAccepted(results), len(results))
+		published = true
+	}
+	return published
+}
+
+// event signs the location event of a position for a target
+func (c *shareConfig) event(ctx context.Context, signer Signer, target shareTarget, loc *location.Location, p *Position) (*nostr.Event, error) {
+	opts := location.EventOptions{
+		D:         p.Track,
+		TTL:       c.ttl,
+		CreatedAt: p.Time,
+		Anon:      c.anon,
+	}
+	if target.recipient == nil {
+		return location.SignPublicEvent(ctx, signer, loc, opts)
+	}
+
+	if len(c.targets) > 1 {
+		// Events of one sender with the same d-tag would replace each other
+		opts.D = recipientDTag(p.Track, target.recipient.pubkey)
+	}
+	if c.wrap {
+		return location.WrapPrivateEvent(ctx, signer, target.recipient.pubkey, loc, opts)
+	}
+	return location.SignPrivateEvent(ctx, signer, target.recipient.pubkey, loc, opts)
+}
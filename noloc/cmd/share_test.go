@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestShareTTLSeconds(t *testing.T) {
+	testConfig(t, "")
+	k.Set("sender", testIdentity("alice").Nsec)
+	k.Set("relay", "wss://relay.example.com")
+
+	// ttl is in seconds for share as for send, also in ~/.noloc.yaml
+	k.Set("ttl", 3600)
+	config, err := loadShareConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ttl != time.Hour {
+		t.Errorf("ttl = %s, want 1h", config.ttl)
+	}
+	if config.maxInterval != 30*time.Minute {
+		t.Errorf("max interval = %s, want half of the ttl", config.maxInterval)
+	}
+
+	testConfig(t, "")
+	k.Set("sender", testIdentity("alice").Nsec)
+	k.Set("relay", "wss://relay.example.com")
+	LoadFlags(shareCmd)
+	if config, err = loadShareConfig(true); err != nil {
+		t.Fatal(err)
+	}
+	if config.ttl != defaultShareTTL*time.Second {
+		t.Errorf("default ttl = %s, want %ds", config.ttl, defaultShareTTL)
+	}
+}
+
+func TestISSDetails(t *testing.T) {
+	testConfig(t, "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"iss_position":{"latitude":"51.5","longitude":"-0.1"},"timestamp":1700000000,"message":"success"}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		public  bool
+		title   string
+		summary string
+		extra   nostr.Tags
+	}{
+		{public: true, title: "ISS", summary: "International Space Station current position"},
+		{public: false, extra: nostr.Tags{{"name", "ISS"}}},
+	}
+	for _, tt := range tests {
+		source := newISS(tt.public)
+		source.apiURL = server.URL
+
+		p, err := source.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Track != issLocationID || p.Lat != 51.5 || p.Lon != -0.1 || !p.Time.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("public %v: position = %+v", tt.public, p)
+		}
+		details := p.Details
+		if details.Title != tt.title || details.Summary != tt.summary || !slices.EqualFunc(details.Extra, tt.extra, slices.Equal) {
+			t.Errorf("public %v: details = %+v, want title %q, summary %q, extra %v", tt.public, details, tt.title, tt.summary, tt.extra)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip46"
+)
+
+// bunkerTimeout bounds each request to a remote signer, long enough for the
+// user to approve it on the signing device
+const bunkerTimeout = 60 * time.Second
+
+// Signer holds the key of a sender or receiver. Commands sign events and
+// NIP-44 encrypt and decrypt only through a Signer, so private keys are
+// handled here and in the identity store.
+type Signer interface {
+	nostr.Keyer
+
+	// PublicKey returns the hex public key without asking a remote signer
+	PublicKey() string
+}
+
+// localSigner is a private key held in memory
+type localSigner struct {
+	keyer.KeySigner
+	pubkey string
+}
+
+func (s *localSigner) PublicKey() string {
+	return s.pubkey
+}
+
+// storeSigner is an identity store entry. Its private key is only read,
+// and an encrypted store only unlocked, when it is first used.
+type storeSigner struct {
+	name   string
+	pubkey string
+	key    Signer
+}
+
+func (s *storeSigner) PublicKey() string {
+	return s.pubkey
+}
+
+func (s *storeSigner) GetPublicKey(ctx context.Context) (string, error) {
+	return s.pubkey, nil
+}
+
+func (s *storeSigner) SignEvent(ctx context.Context, event *nostr.Event) error {
+	key, err := s.unlock()
+	if err != nil {
+		return err
+	}
+	return key.SignEvent(ctx, event)
+}
+
+func (s *storeSigner) Encrypt(ctx context.Context, plaintext, recipientPubkey string) (string, error) {
+	key, err := s.unlock()
+	if err != nil {
+		return "", err
+	}
+	return key.Encrypt(ctx, plaintext, recipientPubkey)
+}
+
+func (s *storeSigner) Decrypt(ctx context.Context, ciphertext, senderPubkey string) (string, error) {
+	key, err := s.unlock()
+	if err != nil {
+		return "", err
+	}
+	return key.Decrypt(ctx, ciphertext, senderPubkey)
+}
+
+func (s *storeSigner) unlock() (Signer, error) {
+	if s.key != nil {
+		return s.key, nil
+	}
+
+	nsec, err := ResolveIdentityReference("@"+s.name, "nsec")
+	if err != nil {
+		return nil, err
+	}
+	key, err := nsecSigner(nsec)
+	if err != nil {
+		return nil, fmt.Errorf("identity '%s': %w", s.name, err)
+	}
+	if key.PublicKey() != s.pubkey {
+		return nil, fmt.Errorf("identity '%s': private key does not match its public key", s.name)
+	}
+
+	s.key = key
+	return key, nil
+}
+
+// bunkerSigner signs and encrypts through a NIP-46 remote signer. The
+// keyer.BunkerSigner of go-nostr encrypts on Decrypt, so this wraps the
+// client directly.
+type bunkerSigner struct {
+	client *nip46.BunkerClient
+	pubkey string
+}
+
+var (
+	_ Signer = (*localSigner)(nil)
+	_ Signer = (*storeSigner)(nil)
+	_ Signer = (*bunkerSigner)(nil)
+)
+
+func (b *bunkerSigner) PublicKey() string {
+	return b.pubkey
+}
+
+func (b *bunkerSigner) GetPublicKey(ctx context.Context) (string, error) {
+	if b.pubkey != "" {
+		return b.pubkey, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bunkerTimeout)
+	defer cancel()
+	pubkey, err := b.client.GetPublicKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("remote signer: %w", err)
+	}
+	b.pubkey = pubkey
+	return pubkey, nil
+}
+
+func (b *bunkerSigner) SignEvent(ctx context.Context, event *nostr.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, bunkerTimeout)
+	defer cancel()
+	if err := b.client.SignEvent(ctx, event); err != nil {
+		return fmt.Errorf("remote signer: %w", err)
+	}
+	return nil
+}
+
+func (b *bunkerSigner) Encrypt(ctx context.Context, plaintext, recipientPubkey string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, bunkerTimeout)
+	defer cancel()
+	ciphertext, err := b.client.NIP44Encrypt(ctx, recipientPubkey, plaintext)
+	if err != nil {
+		return "", fmt.Errorf("remote signer: %w", err)
+	}
+	return ciphertext, nil
+}
+
+func (b *bunkerSigner) Decrypt(ctx context.Context, ciphertext, senderPubkey string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, bunkerTimeout)
+	defer cancel()
+	plaintext, err := b.client.NIP44Decrypt(ctx, senderPubkey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("remote signer: %w", err)
+	}
+	return plaintext, nil
+}
+
+// resolveSigner returns the signer for a sender or receiver given as
+// @identity, nsec or bunker:// URI. Identities added with 'noloc id bunker'
+// sign through their remote signer.
+func resolveSigner(value string) (Signer, error) {
+	switch {
+	case strings.HasPrefix(value, "@"):
+		name := strings.TrimPrefix(value, "@")
+		if name == "" {
+			return nil, fmt.Errorf("invalid identity reference: missing name after @")
+		}
+		identities, err := readIdentities()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load identities: %w", err)
+		}
+		identity, exists := identities[name]
+		if !exists {
+			return nil, fmt.Errorf("identity '%s' not found", name)
+		}
+		return identitySigner(name, identity)
+
+	case strings.HasPrefix(value, "bunker://"):
+		// A one-off connection authorizes a fresh client key
+		return connectBunker(context.Background(), nostr.GeneratePrivateKey(), value)
+
+	case strings.HasPrefix(value, "nsec1"):
+		return nsecSigner(value)
+
+	default:
+		return nil, fmt.Errorf("invalid key %q: must be nsec, bunker:// URI or @identity reference", value)
+	}
+}
+
+// identitySigner returns the signer of a stored identity
+func identitySigner(name string, identity Identity) (Signer, error) {
+	if identity.Contact {
+		return nil, fmt.Errorf("'%s' is a watch-only contact and has no private key", name)
+	}
+	if identity.Bunker != "" {
+		return identityBunkerSigner(name, identity)
+	}
+	if identity.isSeed() {
+		return nil, fmt.Errorf("'%s' is an ephemeral seed, use --ephemeral @%s", name, name)
+	}
+	if identity.Nsec == "" && identity.Ncryptsec == "" {
+		return nil, fmt.Errorf("identity '%s' has no private key", name)
+	}
+	return &storeSigner{name: name, pubkey: identity.Hex}, nil
+}
+
+// nsecSigner returns an in-memory signer for an nsec
+func nsecSigner(nsec string) (*localSigner, error) {
+	prefix, skRaw, err := nip19.Decode(nsec)
+	if err != nil || prefix != "nsec" {
+		return nil, fmt.Errorf("failed to decode nsec")
+	}
+	return skSigner(skRaw.(string))
+}
+
+// skSigner returns an in-memory signer for a hex private key
+func skSigner(sk string) (*localSigner, error) {
+	kr, err := keyer.NewPlainKeySigner(sk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	pubkey, _ := kr.GetPublicKey(context.Background())
+	return &localSigner{KeySigner: kr, pubkey: pubkey}, nil
+}
+
+// identityBunkerSigner reconnects to the remote signer of an identity with
+// the client key it was authorized with, unlocking an encrypted store for it
+func identityBunkerSigner(name string, identity Identity) (Signer, error) {
+	target, relays, _, err := parseBunkerURI(identity.Bunker)
+	if err != nil {
+		return nil, err
+	}
+
+	clientSK := identity.BunkerClient
+	if clientSK == "" && identity.BunkerClientNcryptsec != "" {
+		identities, err := loadIdentities()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load identities: %w", err)
+		}
+		clientSK = identities[name].BunkerClient
+	}
+	if clientSK == "" {
+		return nil, fmt.Errorf("identity '%s' has no remote signer client key", name)
+	}
+
+	client := nip46.NewBunker(context.Background(), clientSK, target, relays, nil, printAuthURL)
+	return &bunkerSigner{client: client, pubkey: identity.Hex}, nil
+}
+
+// connectBunker sends the NIP-46 connect request of a bunker:// URI
+func connectBunker(ctx context.Context, clientSK, uri string) (*bunkerSigner, error) {
+	target, relays, secret, err := parseBunkerURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// The client listens for responses for the rest of the process
+	client := nip46.NewBunker(context.Background(), clientSK, target, relays, nil, printAuthURL)
+
+	connectCtx, cancel := context.WithTimeout(ctx, bunkerTimeout)
+	defer cancel()
+	if _, err := client.RPC(connectCtx, "connect", []string{target, secret}); err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+	}
+
+	signer := &bunkerSigner{client: client}
+	if _, err := signer.GetPublicKey(ctx); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// parseBunkerURI splits bunker://<remote-signer-pubkey>?relay=...&secret=...
+func parseBunkerURI(uri string) (target string, relays []string, secret string, err error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "bunker" {
+		return "", nil, "", fmt.Errorf("invalid bunker URI %q: must be bunker://<pubkey>?relay=...", uri)
+	}
+
+	target = parsed.Host
+	if !nostr.IsValidPublicKey(target) {
+		return "", nil, "", fmt.Errorf("invalid bunker URI: %q is not a hex public key", target)
+	}
+
+	relays = parsed.Query()["relay"]
+	if len(relays) == 0 {
+		return "", nil, "", fmt.Errorf("invalid bunker URI: no relay given")
+	}
+
+	return target, relays, parsed.Query().Get("secret"), nil
+}
+
+// stripBunkerSecret removes the single-use connect secret before a bunker
+// URI is stored
+func stripBunkerSecret(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := parsed.Query()
+	query.Del("secret")
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// printAuthURL shows the URL a remote signer asks the user to open to
+// approve a request
+func printAuthURL(authURL string) {
+	fmt.Fprintf(os.Stderr, "Remote signer requests approval, open: %s\n", authURL)
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip46"
+)
+
+const testBunkerSecret = "s3cret"
+
+// startTestBunker runs a NIP-46 remote signer for a new key on the relay.
+// Only clients that connected with testBunkerSecret are served.
+func startTestBunker(t *testing.T, relayURL string) (pubkey, uri string) {
+	t.Helper()
+	sk := nostr.GeneratePrivateKey()
+	pubkey, _ = nostr.GetPublicKey(sk)
+
+	var mu sync.Mutex
+	authorized := make(map[string]bool)
+	signer := nip46.NewStaticKeySigner(sk)
+	signer.AuthorizeRequest = func(harmless bool, from, secret string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if secret == testBunkerSecret {
+			authorized[from] = true
+		}
+		return authorized[from]
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	relay, err := nostr.RelayConnect(ctx, relayURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := relay.Subscribe(ctx, nostr.Filters{{
+		Kinds: []int{nostr.KindNostrConnect},
+		Tags:  nostr.TagMap{"p": []string{pubkey}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for event := range sub.Events {
+			_, _, response, err := signer.HandleRequest(ctx, event)
+			if err == nil {
+				relay.Publish(ctx, response)
+			}
+		}
+	}()
+
+	return pubkey, "bunker://" + pubkey + "?relay=" + relayURL + "&secret=" + testBunkerSecret
+}
+
+func TestBunkerIdentityEncryptedStore(t *testing.T) {
+	testConfig(t, "")
+	k.Set("passphrase", testPassphrase)
+	writeEncryptedStore(t, map[string]Identity{"alice": testIdentity("alice")})
+
+	relayURL := startTestRelay(t)
+	bunkerPubkey, uri := startTestBunker(t, relayURL)
+
+	if err := addBunkerIdentity(idBunkerCmd, []string{"phone", uri}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := readIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone := stored["phone"]
+	if phone.Hex != bunkerPubkey {
+		t.Errorf("pubkey = %s, want %s", phone.Hex, bunkerPubkey)
+	}
+	if phone.BunkerClient != "" || phone.BunkerClientNcryptsec == "" {
+		t.Fatalf("client key stored in plaintext: %+v", phone)
+	}
+	if strings.Contains(phone.Bunker, testBunkerSecret) {
+		t.Errorf("connect secret stored in %s", phone.Bunker)
+	}
+
+	// A new process unlocks the store to reuse the authorized client key
+	storePassphrase = ""
+	signer, err := resolveSigner("@phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	event := &nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Content: "hello"}
+	if err := signer.SignEvent(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := event.CheckSignature(); !ok || event.PubKey != bunkerPubkey {
+		t.Errorf("event signed by %s, want %s", event.PubKey, bunkerPubkey)
+	}
+
+	other := testIdentity("bob")
+	ciphertext, err := signer.Encrypt(ctx, "u4pruydqqvj", other.Hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := signer.Decrypt(ctx, ciphertext, other.Hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "u4pruydqqvj" {
+		t.Errorf("decrypted %q", plaintext)
+	}
+}
+
+func TestBunkerIdentityWrongPassphrase(t *testing.T) {
+	testConfig(t, "")
+	k.Set("passphrase", "wrong")
+	identities := map[string]Identity{"phone": {
+		Name:         "phone",
+		Hex:          strings.Repeat("a", 64),
+		Bunker:       "bunker://" + testIdentity("bunker").Hex + "?relay=ws://127.0.0.1:1",
+		BunkerClient: nostr.GeneratePrivateKey(),
+	}}
+	if err := encryptIdentities(identities, testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeIdentities(identities); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resolveSigner("@phone"); err == nil || !strings.Contains(err.Error(), "wrong identity store passphrase") {
+		t.Errorf("err = %v, want wrong passphrase", err)
+	}
+}
+
+func TestParseBunkerURI(t *testing.T) {
+	pubkey := strings.Repeat("ab", 32)
+	tests := []struct {
+		uri     string
+		relays  int
+		secret  string
+		wantErr bool
+	}{
+		{uri: "bunker://" + pubkey + "?relay=wss://a.example&relay=wss://b.example&secret=x", relays: 2, secret: "x"},
+		{uri: "bunker://" + pubkey + "?relay=wss://a.example", relays: 1},
+		{uri: "bunker://" + pubkey, wantErr: true},
+		{uri: "bunker://npub1abc?relay=wss://a.example", wantErr: true},
+		{uri: "nostrconnect://" + pubkey + "?relay=wss://a.example", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		target, relays, secret, err := parseBunkerURI(tt.uri)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: want error", tt.uri)
+			}
+			continue
+		}
+		if err != nil || target != pubkey || len(relays) != tt.relays || secret != tt.secret {
+			t.Errorf("%s: got %s, %v, %q, %v", tt.uri, target, relays, secret, err)
+		}
+	}
+}
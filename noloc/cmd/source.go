@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"noloc/location"
+)
+
+// Position is one reading of a position source
+type Position struct {
+	Track    string  // Moving object, used as d-tag so its events replace each other
+	Lat      float64 // Degrees
+	Lon      float64 // Degrees
+	Accuracy float64 // Meters, 0 = unknown
+	Time     time.Time
+	// Title, summary, hashtags and extra tags of the published location,
+	// the geohash and accuracy are set when it is shared
+	Details location.Location
+}
+
+// PositionSource produces the positions shared by 'noloc share'. Adding a
+// source takes a type implementing it and an entry in positionSources.
+type PositionSource interface {
+	// Next blocks until the next position is available. Polling sources
+	// wait for their interval here, and must also wait after errors.
+	// Errors are logged and sharing goes on, except io.EOF, which ends it.
+	Next(ctx context.Context) (*Position, error)
+}
+
+// positionSources creates the sources of 'noloc share' by name, with their
+// options read from the flags
+var positionSources = map[string]func() (PositionSource, error){
+	"gpsd":   newGPSDSource,
+	"iss":    newISSSource,
+	"random": newRandomSource,
+}
+
+// positionSource looks up the constructor of a source by name
+func positionSource(name string) (func() (PositionSource, error), error) {
+	create, ok := positionSources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown position source %q: must be one of %s", name, strings.Join(sourceNames(), ", "))
+	}
+	return create, nil
+}
+
+// sourceNames lists the source names in order
+func sourceNames() []string {
+	var names []string
+	for name := range positionSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// updateInterval returns the polling interval of --interval or the
+// update.interval setting
+func updateInterval() time.Duration {
+	interval := k.Int("interval")
+	if interval <= 0 {
+		interval = k.Int("update.interval")
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+	}
+	return time.Duration(interval) * time.Second
+}
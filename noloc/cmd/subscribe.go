@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"noloc/location"
+)
+
+const (
+	// healthCheckInterval is how often an idle subscription pings the relay
+	healthCheckInterval = 30 * time.Second
+	pingTimeout         = 10 * time.Second
+)
+
+// subscribeWithReconnect delivers events matching the filters to handle
+// until ctx is cancelled. When the connection drops, the relay closes the
+// subscription or a ping goes unanswered, it reconnects with exponential
+// backoff and resubscribes with since set to the last seen created_at, so
+// events published during the outage are not lost. Gift wraps have random
+// past timestamps, so filters for them resume from the connection loss minus
+// location.WrapJitter instead. Only the first connection failure is
+// returned as an error.
+func subscribeWithReconnect(ctx context.Context, relayURL string, filters nostr.Filters, handle func(*nostr.Event)) error {
+	var lastSeen nostr.Timestamp
+	seenAtLast := make(map[string]bool) // Event IDs at lastSeen, redelivered after resubscribing
+	var wrapsSince nostr.Timestamp
+	wraps := &seenWraps{seen: make(map[string]nostr.Timestamp)}
+	connected := false
+	failures := 0
+
+	for {
+		relay, sub, err := connectAndSubscribe(ctx, relayURL, filters, lastSeen, wrapsSince)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !connected {
+				return err
+			}
+
+			failures++
+			delay := reconnectDelay(failures)
+			log.Printf("Reconnect to %s failed: %v (retrying in %s)", relayURL, err, delay)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(delay):
+			}
+			continue
+		}
+
+		if connected {
+			log.Printf("Reconnected to %s, resubscribed since %s", relayURL, lastSeen.Time().Format("2006-01-02 15:04:05"))
+		} else {
+			log.Printf("Subscribed to %s. Press Ctrl+C to exit.", relayURL)
+		}
+		connected = true
+		failures = 0
+
+		reason := consumeSubscription(ctx, relay, sub, func(event *nostr.Event) {
+			if event.Kind == nostr.KindGiftWrap {
+				if wraps.add(event, time.Now()) {
+					handle(event)
+				}
+				return
+			}
+			if seenAtLast[event.ID] {
+				return
+			}
+			if event.CreatedAt > lastSeen {
+				lastSeen = event.CreatedAt
+				seenAtLast = make(map[string]bool)
+			}
+			if event.CreatedAt == lastSeen {
+				seenAtLast[event.ID] = true
+			}
+			handle(event)
+		})
+		sub.Unsub()
+		relay.Close()
+		wrapsSince = nostr.Timestamp(time.Now().Add(-location.WrapJitter).Unix())
+		pruneSeen(wraps.seen, wrapsSince)
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("Connection to %s lost: %s", relayURL, reason)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(minReconnectDelay):
+		}
+	}
+}
+
+// seenWraps remembers the created_at of delivered gift wraps, which a
+// resubscription delivers again from anywhere in the jitter window. Wraps
+// created before the window are never delivered again and are forgotten.
+type seenWraps struct {
+	seen   map[string]nostr.Timestamp
+	pruned time.Time
+}
+
+// add reports whether the gift wrap is new and remembers it
+func (s *seenWraps) add(event *nostr.Event, now time.Time) bool {
+	if now.Sub(s.pruned) > location.WrapJitter {
+		pruneSeen(s.seen, nostr.Timestamp(now.Add(-location.WrapJitter).Unix()))
+		s.pruned = now
+	}
+	if _, ok := s.seen[event.ID]; ok {
+		return false
+	}
+	s.seen[event.ID] = event.CreatedAt
+	return true
+}
+
+// connectAndSubscribe dials the relay and opens the subscription, limited
+// to events since the given timestamps when they are set: wrapsSince for
+// gift wrap filters, since for all others
+func connectAndSubscribe(ctx context.Context, relayURL string, filters nostr.Filters, since, wrapsSince nostr.Timestamp) (*nostr.Relay, *nostr.Subscription, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	relay, err := nostr.RelayConnect(dialCtx, relayURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to relay: %w", err)
+	}
+
+	if since > 0 || wrapsSince > 0 {
+		resumed := make(nostr.Filters, len(filters))
+		for i, filter := range filters {
+			resumeAt := since
+			if slices.Contains(filter.Kinds, nostr.KindGiftWrap) {
+				resumeAt = wrapsSince
+			}
+			if resumeAt > 0 {
+				filter.Since = &resumeAt
+			}
+			resumed[i] = filter
+		}
+		filters = resumed
+	}
+
+	sub, err := relay.Subscribe(ctx, filters)
+	if err != nil {
+		relay.Close()
+		return nil, nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	return relay, sub, nil
+}
+
+// consumeSubscription passes events to handle until ctx is cancelled or the
+// subscription ends, and returns the reason it ended
+func consumeSubscription(ctx context.Context, relay *nostr.Relay, sub *nostr.Subscription, handle func(*nostr.Event)) string {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "shutting down"
+
+		case reason := <-sub.ClosedReason:
+			return fmt.Sprintf("subscription closed by relay: %s", reason)
+
+		case <-sub.Context.Done():
+			return fmt.Sprintf("subscription ended: %v", context.Cause(sub.Context))
+
+		case <-ticker.C:
+			conn := relay.Connection
+			if conn == nil {
+				return "connection closed"
+			}
+			pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				return fmt.Sprintf("health check failed: %v", err)
+			}
+
+		case event := <-sub.Events:
+			if event != nil {
+				handle(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"noloc/location"
+)
+
+// signedNote returns a signed kind 1 event with the given timestamp
+func signedNote(t *testing.T, sk, content string, createdAt nostr.Timestamp) *nostr.Event {
+	t.Helper()
+	event := &nostr.Event{Kind: 1, CreatedAt: createdAt, Content: content}
+	if err := event.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestSubscribeWithReconnect(t *testing.T) {
+	relay, url := newTestRelay(t)
+	sk := nostr.GeneratePrivateKey()
+	now := nostr.Now()
+
+	before := signedNote(t, sk, "before", now-20)
+	last := signedNote(t, sk, "last", now-10)
+	relay.publish(before)
+	relay.publish(last)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan *nostr.Event, 10)
+	done := make(chan error)
+	go func() {
+		done <- subscribeWithReconnect(ctx, url, nostr.Filters{{Kinds: []int{1}}}, func(event *nostr.Event) {
+			received <- event
+		})
+	}()
+
+	next := func() *nostr.Event {
+		t.Helper()
+		select {
+		case event := <-received:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return nil
+		}
+	}
+	// Stored events may arrive in any order
+	expect := func(events ...*nostr.Event) {
+		t.Helper()
+		want := make(map[string]bool)
+		for _, event := range events {
+			want[event.ID] = true
+		}
+		for len(want) > 0 {
+			got := next()
+			if !want[got.ID] {
+				t.Fatalf("unexpected event %q", got.Content)
+			}
+			delete(want, got.ID)
+		}
+	}
+	expect(before, last)
+
+	// Events published while the listener is disconnected arrive after it
+	// resubscribes, including one in the same second as the last one seen,
+	// which the relay sends again
+	relay.disconnect()
+	sameSecond := signedNote(t, sk, "same second", last.CreatedAt)
+	outage := signedNote(t, sk, "outage", now-5)
+	relay.publish(sameSecond)
+	relay.publish(outage)
+
+	expect(sameSecond, outage)
+
+	live := signedNote(t, sk, "live", nostr.Now())
+	relay.publish(live)
+	if got := next(); got.ID != live.ID {
+		t.Fatalf("event %q, want %q", got.Content, live.Content)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("subscribeWithReconnect: %v", err)
+	}
+	select {
+	case event := <-received:
+		t.Errorf("duplicate event %q", event.Content)
+	default:
+	}
+}
+
+func TestSubscribeWithReconnectFirstFailure(t *testing.T) {
+	err := subscribeWithReconnect(context.Background(), closedRelayURL(t), nostr.Filters{{Kinds: []int{1}}}, func(*nostr.Event) {})
+	if err == nil {
+		t.Error("want the error of the first connection")
+	}
+}
+
+func TestSeenWraps(t *testing.T) {
+	now := time.Now()
+	wraps := &seenWraps{seen: make(map[string]nostr.Timestamp)}
+	old := &nostr.Event{ID: "old", CreatedAt: nostr.Timestamp(now.Add(-location.WrapJitter + time.Hour).Unix())}
+	recent := &nostr.Event{ID: "recent", CreatedAt: nostr.Timestamp(now.Unix())}
+
+	if !wraps.add(old, now) || !wraps.add(recent, now) {
+		t.Fatal("new gift wraps reported as seen")
+	}
+	if wraps.add(old, now) || wraps.add(recent, now.Add(time.Hour)) {
+		t.Error("redelivered gift wraps reported as new")
+	}
+
+	// Once the jitter window has moved past the wraps they are forgotten
+	later := now.Add(location.WrapJitter + time.Minute)
+	if !wraps.add(&nostr.Event{ID: "new", CreatedAt: nostr.Timestamp(later.Unix())}, later) {
+		t.Error("new gift wrap reported as seen")
+	}
+	if _, ok := wraps.seen["new"]; !ok || len(wraps.seen) != 1 {
+		t.Errorf("seen = %v, want only new", wraps.seen)
+	}
+}
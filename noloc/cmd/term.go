@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// promptInput is read by the prompts, one byte at a time so that several
+// prompts can read their lines from a pipe
+var promptInput io.Reader = os.Stdin
+
+// confirm asks a yes/no question on stderr. Anything but y or yes, or no
+// input at all, is a no.
+func confirm(prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := readLine()
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// readPassword reads a line with terminal echo turned off when stdin is a
+// terminal, or as is from a pipe
+func readPassword() (string, error) {
+	if f, ok := promptInput.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		password, err := term.ReadPassword(int(f.Fd()))
+		return string(password), err
+	}
+	return readLine()
+}
+
+// readLine reads a line from promptInput without reading past it. The
+// error is only returned when no input is left.
+func readLine() (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := promptInput.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			if len(line) == 0 {
+				return "", err
+			}
+			break
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
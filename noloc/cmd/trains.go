@@ -8,13 +8,14 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
-	"github.com/mmcloughlin/geohash"
 	"github.com/nbd-wtf/go-nostr"
-	"github.com/nbd-wtf/go-nostr/nip19"
 	"github.com/spf13/cobra"
+
+	"noloc/location"
 )
 
 type TrainLocation struct {
@@ -44,7 +45,7 @@ replaceable events using the train number as the d-tag.`,
 
 func init() {
 	rootCmd.AddCommand(trainsCmd)
-	trainsCmd.Flags().StringP("sender", "s", "", "Sender private key (nsec... or @identity)")
+	trainsCmd.Flags().StringP("sender", "s", "", "Sender private key (nsec..., bunker:// URI or @identity)")
 	trainsCmd.Flags().IntP("ttl", "t", 3600, "Time-to-live for events in seconds")
 	trainsCmd.Flags().IntP("precision", "p", 7, "Geohash precision (1-12)")
 
@@ -54,8 +55,7 @@ func init() {
 func runTrains(cmd *cobra.Command, args []string) error {
 	LoadFlags(cmd)
 
-	senderNsec := k.String("sender")
-	relayURL := k.String("relay")
+	sender := k.String("sender")
 	ttl := k.Int("ttl")
 	precision := k.Int("precision")
 
@@ -63,34 +63,43 @@ func runTrains(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("precision must be between 1 and 12")
 	}
 
-	// Decode sender private key
-	_, skRaw, err := nip19.Decode(senderNsec)
+	relayURLs, err := relayURLs()
 	if err != nil {
-		return fmt.Errorf("failed to decode sender nsec: %w", err)
+		return err
 	}
-	senderSK := skRaw.(string)
 
-	// Get sender public key
-	senderPubkey, err := nostr.GetPublicKey(senderSK)
+	signer, err := resolveSigner(sender)
 	if err != nil {
-		return fmt.Errorf("failed to get sender public key: %w", err)
+		return fmt.Errorf("failed to resolve sender: %w", err)
 	}
+	senderPubkey := signer.PublicKey()
+	policy := privacyPolicy(identityName(senderPubkey))
 
 	fmt.Printf("🚂 Train Location Tracker\n")
 	fmt.Printf("  Sender: %s\n", senderPubkey[:8]+"...")
-	fmt.Printf("  Relay: %s\n", relayURL)
+	fmt.Printf("  Relays: %s (quorum %d)\n", strings.Join(relayURLs, ", "), publishQuorum(len(relayURLs)))
 	fmt.Printf("  TTL: %d seconds\n", ttl)
 	fmt.Printf("  Geohash precision: %d\n", precision)
+	if policy.Active() {
+		fmt.Printf("  Privacy: %s\n", policy)
+	}
 
-	// Connect to Nostr relay
+	// Connect to Nostr relays up front, the pool keeps them open
 	ctx := context.Background()
-	relay, err := nostr.RelayConnect(ctx, relayURL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to relay: %w", err)
+	connected := 0
+	for _, relayURL := range relayURLs {
+		if _, err := pool.ensure(ctx, relayURL); err != nil {
+			fmt.Printf("⚠️  Failed to connect to relay %s: %v\n", relayURL, err)
+			continue
+		}
+		connected++
 	}
-	defer relay.Close()
+	if connected == 0 {
+		return fmt.Errorf("failed to connect to any relay")
+	}
+	defer pool.close()
 
-	fmt.Printf("✅ Connected to Nostr relay\n\n")
+	fmt.Printf("✅ Connected to %d/%d Nostr relays\n\n", connected, len(relayURLs))
 
 	// Create MQTT client
 	clientID := fmt.Sprintf("noloc_train_%d", rand.Intn(10000))
@@ -113,14 +122,20 @@ func runTrains(cmd *cobra.Command, args []string) error {
 		}
 
 		// Create and send Nostr event
-		event, err := createTrainLocationEvent(trainLoc, senderSK, senderPubkey, ttl, precision)
+		event, err := createTrainLocationEvent(trainLoc, signer, ttl, precision, policy)
 		if err != nil {
 			fmt.Printf("❌ Failed to create event for train %d: %v\n", trainLoc.TrainNumber, err)
 			return
 		}
 
-		if err := relay.Publish(ctx, *event); err != nil {
+		results, err := publishToRelays(relayURLs, event)
+		if err != nil {
 			fmt.Printf("❌ Failed to publish event for train %d: %v\n", trainLoc.TrainNumber, err)
+			for _, r := range results {
+				if r.err != nil {
+					fmt.Printf("   %s: %s\n", r.relayURL, r.message())
+				}
+			}
 			return
 		}
 
@@ -163,7 +178,7 @@ func runTrains(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func createTrainLocationEvent(trainLoc TrainLocation, senderSK, senderPubkey string, ttl, precision int) (*nostr.Event, error) {
+func createTrainLocationEvent(trainLoc TrainLocation, signer Signer, ttl, precision int, policy location.Policy) (*nostr.Event, error) {
 	// Get coordinates
 	if len(trainLoc.Location.Coordinates) < 2 {
 		return nil, fmt.Errorf("invalid coordinates")
@@ -171,26 +186,12 @@ func createTrainLocationEvent(trainLoc TrainLocation, senderSK, senderPubkey str
 	lon := trainLoc.Location.Coordinates[0]
 	lat := trainLoc.Location.Coordinates[1]
 
-	// Create geohash
-	gh := geohash.EncodeWithPrecision(lat, lon, uint(precision))
-
-	// Calculate expiration
-	expiration := time.Now().Add(time.Duration(ttl) * time.Second).Unix()
-
-	// Build tags for public event (kind 30472)
-	tags := nostr.Tags{
-		{"g", gh}, // Required geohash
-		{"d", fmt.Sprintf("train-%d", trainLoc.TrainNumber)},     // Train identifier
-		{"expiration", fmt.Sprintf("%d", expiration)},            // Expiration time
-		{"title", fmt.Sprintf("Train %d", trainLoc.TrainNumber)}, // Train number as title
-		{"speed", strconv.Itoa(trainLoc.Speed)},                  // Speed in summary
-		{"accuracy", strconv.Itoa(trainLoc.Accuracy)},            // Accuracy in meters
+	loc := &location.Location{
+		Title:    fmt.Sprintf("Train %d", trainLoc.TrainNumber),
+		Hashtags: []string{"train", "finland", "railway"},
+		Extra:    nostr.Tags{{"speed", strconv.Itoa(trainLoc.Speed)}},
 	}
-
-	// Add hashtags
-	tags = append(tags, nostr.Tag{"t", "train"})
-	tags = append(tags, nostr.Tag{"t", "finland"})
-	tags = append(tags, nostr.Tag{"t", "railway"})
+	loc.Geohash, loc.Accuracy = policy.Obfuscate(lat, lon, float64(trainLoc.Accuracy), precision)
 
 	// Parse timestamp and use it for created_at
 	timestamp, err := time.Parse(time.RFC3339, trainLoc.Timestamp)
@@ -198,18 +199,9 @@ func createTrainLocationEvent(trainLoc TrainLocation, senderSK, senderPubkey str
 		timestamp = time.Now()
 	}
 
-	// Create public location event (kind 30472)
-	event := &nostr.Event{
-		PubKey:    senderPubkey,
-		CreatedAt: nostr.Timestamp(timestamp.Unix()),
-		Kind:      30472, // Public location event kind
-		Tags:      tags,
-		Content:   "", // No content field for public events
-	}
-
-	if err := event.Sign(senderSK); err != nil {
-		return nil, fmt.Errorf("failed to sign event: %w", err)
-	}
-
-	return event, nil
+	return location.SignPublicEvent(context.Background(), signer, loc, location.EventOptions{
+		D:         fmt.Sprintf("train-%d", trainLoc.TrainNumber),
+		TTL:       time.Duration(ttl) * time.Second,
+		CreatedAt: timestamp,
+	})
 }
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/spf13/cobra"
+
+	"noloc/location"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Validate location events against the specification",
+	Long: `Check that location events conform to doc/NostrLocation.md and report
+violations for each event with machine-readable codes.
+
+Events are read as a JSON array or as newline-delimited JSON from the given
+file, or from stdin when no file (or "-") is given. With --query, events are
+fetched from the relay instead. Encrypted content of kind 30473 is checked
+only when --receiver is given.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().StringP("receiver", "r", "", "Receiver private key for decrypting kind 30473 content (nsec..., bunker:// URI or @identity)")
+	validateCmd.Flags().Bool("query", false, "Fetch events from the relay instead of reading input")
+	validateCmd.Flags().String("author", "", "Only query events from this author (npub, nprofile, hex, user@domain or @identity)")
+	validateCmd.Flags().Int("limit", 100, "Maximum number of events to query from the relay")
+	validateCmd.Flags().Bool("json", false, "Print reports as newline-delimited JSON")
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	LoadFlags(cmd)
+
+	var receiver nostr.Cipher
+	if value := k.String("receiver"); value != "" {
+		signer, err := resolveSigner(value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve receiver: %w", err)
+		}
+		receiver = signer
+	}
+
+	var events []*nostr.Event
+	var err error
+	if k.Bool("query") {
+		events, err = queryLocationEvents()
+	} else {
+		events, err = readEventsInput(args)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Violations are reported per event, not as command usage errors
+	cmd.SilenceUsage = true
+
+	jsonOutput := k.Bool("json")
+	invalid := 0
+	for _, event := range events {
+		report := location.Validate(event, receiver)
+		if !report.Valid() {
+			invalid++
+		}
+
+		if jsonOutput {
+			data, err := json.Marshal(report)
+			if err != nil {
+				return fmt.Errorf("failed to marshal report: %w", err)
+			}
+			fmt.Println(string(data))
+			continue
+		}
+
+		status := "OK"
+		if !report.Valid() {
+			status = "INVALID"
+		}
+		if report.Kind == location.KindPrivate && !report.Decrypted && receiver == nil {
+			status += " (content not checked)"
+		}
+		fmt.Printf("%s kind %d: %s\n", report.EventID, report.Kind, status)
+		for _, v := range report.Violations {
+			fmt.Printf("  [%s] %s\n", v.Code, v.Message)
+		}
+	}
+
+	if !jsonOutput {
+		fmt.Printf("\nValidated %d events: %d valid, %d invalid\n", len(events), len(events)-invalid, invalid)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d events failed validation", invalid, len(events))
+	}
+	return nil
+}
+
+// readEventsInput reads events from a file or stdin as a JSON array or a
+// stream of JSON objects
+func readEventsInput(args []string) ([]*nostr.Event, error) {
+	var input io.Reader = os.Stdin
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input: %w", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	reader := bufio.NewReader(input)
+	decoder := json.NewDecoder(reader)
+
+	// Peek at the first non-space byte to detect an array
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		if strings.ContainsRune(" \t\r\n", rune(b[0])) {
+			reader.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			var events []*nostr.Event
+			if err := decoder.Decode(&events); err != nil {
+				return nil, fmt.Errorf("failed to parse event array: %w", err)
+			}
+			return events, nil
+		}
+		break
+	}
+
+	var events []*nostr.Event
+	for {
+		var event nostr.Event
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse event %d: %w", len(events)+1, err)
+		}
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
+
+// queryLocationEvents fetches stored location events from the relay
+func queryLocationEvents() ([]*nostr.Event, error) {
+	relayURL, err := primaryRelayURL()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := nostr.Filter{
+		Kinds: []int{location.KindPublic, location.KindPrivate},
+		Limit: k.Int("limit"),
+	}
+
+	if author := k.String("author"); author != "" {
+		pubkey, err := resolvePubkey(author)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve author: %w", err)
+		}
+		filter.Authors = []string{pubkey}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	relay, err := nostr.RelayConnect(ctx, relayURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to relay: %w", err)
+	}
+	defer relay.Close()
+
+	events, err := relay.QuerySync(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relay: %w", err)
+	}
+
+	return events, nil
+}
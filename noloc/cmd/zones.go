@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"noloc/location"
+)
+
+// zoneConfig is a private zone as written in ~/.noloc.yaml
+type zoneConfig struct {
+	Name      string   `koanf:"name"`
+	Center    string   `koanf:"center"`  // Coordinates of a circle center
+	Radius    float64  `koanf:"radius"`  // Circle radius in meters
+	Polygon   []string `koanf:"polygon"` // Coordinates of polygon vertices
+	Action    string   `koanf:"action"`  // skip (default), coarse or placeholder
+	Label     string   `koanf:"label"`   // Placeholder title
+	Precision int      `koanf:"precision"`
+}
+
+// privateZones returns the default private zones and those of the named
+// identities, configured in ~/.noloc.yaml:
+//
+//	zones:
+//	  alice:
+//	    - name: home
+//	      center: 60.1699,24.9384
+//	      radius: 300
+//	      action: coarse
+//	    - name: office
+//	      polygon: ["60.170,24.940", "60.171,24.945", "60.168,24.946"]
+//	      action: placeholder
+//	      label: At work
+//
+// Empty names are skipped.
+func privateZones(names ...string) ([]location.Zone, error) {
+	var zones []location.Zone
+	for _, name := range append([]string{"default"}, names...) {
+		if name == "" {
+			continue
+		}
+		var configs []zoneConfig
+		if err := k.Unmarshal("zones."+name, &configs); err != nil {
+			return nil, fmt.Errorf("invalid zones for %s: %w", name, err)
+		}
+		for _, c := range configs {
+			zone, err := c.zone()
+			if err != nil {
+				return nil, fmt.Errorf("invalid zones for %s: %w", name, err)
+			}
+			zones = append(zones, zone)
+		}
+	}
+	return zones, nil
+}
+
+// zone parses the configured zone
+func (c zoneConfig) zone() (location.Zone, error) {
+	zone := location.Zone{
+		Name:      c.Name,
+		Radius:    c.Radius,
+		Action:    c.Action,
+		Label:     c.Label,
+		Precision: c.Precision,
+	}
+	if zone.Action == "" {
+		zone.Action = location.ZoneSkip
+	}
+
+	if c.Center != "" {
+		lat, lon, _, err := location.ParseCoordinates(c.Center)
+		if err != nil {
+			return zone, fmt.Errorf("zone %s: %w", c.Name, err)
+		}
+		zone.Lat, zone.Lon = lat, lon
+	} else if len(c.Polygon) == 0 {
+		return zone, fmt.Errorf("zone %s: needs a center or a polygon", c.Name)
+	}
+	for _, vertex := range c.Polygon {
+		lat, lon, _, err := location.ParseCoordinates(vertex)
+		if err != nil {
+			return zone, fmt.Errorf("zone %s: %w", c.Name, err)
+		}
+		zone.Polygon = append(zone.Polygon, [2]float64{lat, lon})
+	}
+
+	return zone, zone.Validate()
+}
+
+// describeZone tells what was done to a position inside a zone
+func describeZone(zone *location.Zone) string {
+	switch zone.Action {
+	case location.ZoneSkip:
+		return fmt.Sprintf("inside private zone %s, not published", zone.Name)
+	case location.ZonePlaceholder:
+		return fmt.Sprintf("inside private zone %s, published as placeholder", zone.Name)
+	default:
+		return fmt.Sprintf("inside private zone %s, published coarsely", zone.Name)
+	}
+}
+
+// printDryRun shows the location that would be published for a receiver
+func printDryRun(label string, loc *location.Location, zone *location.Zone, ok bool, policy location.Policy) {
+	if !ok {
+		fmt.Printf("  %s: %s\n", label, describeZone(zone))
+		return
+	}
+
+	fmt.Printf("  %s: %s\n", label, describeProtected(loc, zone, policy))
+}
+
+// describeProtected describes a location after zones and policy applied
+func describeProtected(loc *location.Location, zone *location.Zone, policy location.Policy) string {
+	description := "geohash " + loc.Geohash
+	if loc.Accuracy > 0 {
+		description += ", accuracy " + formatDistance(loc.Accuracy)
+	}
+	if loc.Title != "" {
+		description += fmt.Sprintf(", title %q", loc.Title)
+	}
+	if zone != nil {
+		description += fmt.Sprintf(" (%s)", describeZone(zone))
+	} else if policy.Active() {
+		description += fmt.Sprintf(" (privacy: %s)", policy)
+	}
+	return description
+}
+
+// positionGuard applies the private zones of a sender and the privacy
+// policies of the sender and its receiver to each published position
+type positionGuard struct {
+	zones  []location.Zone
+	policy location.Policy
+}
+
+// newPositionGuard loads the zones of the sender and the strictest policy
+// of the sender and the receivers, all given by identity name
+func newPositionGuard(sender string, receivers ...string) (*positionGuard, error) {
+	zones, err := privateZones(sender)
+	if err != nil {
+		return nil, err
+	}
+	return &positionGuard{zones: zones, policy: privacyPolicy(append([]string{sender}, receivers...)...)}, nil
+}
+
+// zoneSkipError is returned for positions inside a zone that skips them
+type zoneSkipError struct {
+	zone *location.Zone
+}
+
+func (e *zoneSkipError) Error() string {
+	return describeZone(e.zone)
+}
+
+// protect sets the geohash and accuracy of loc for a position, with a fresh
+// random offset. It returns the zone the position is in, and a
+// *zoneSkipError when the position must not be published.
+func (g *positionGuard) protect(loc *location.Location, lat, lon, accuracy float64, precision int) (*location.Zone, error) {
+	return g.apply(loc, lat, lon, accuracy, precision, location.RandomOffset())
+}
+
+// apply is protect with a given offset, shared by the receivers of one
+// position so that they cannot average it out
+func (g *positionGuard) apply(loc *location.Location, lat, lon, accuracy float64, precision int, offset location.Offset) (*location.Zone, error) {
+	zone, ok := location.Protect(loc, g.zones, g.policy, lat, lon, accuracy, precision, offset)
+	if !ok {
+		return zone, &zoneSkipError{zone: zone}
+	}
+	return zone, nil
+}
+
+// logPrivacy logs the active policy and the number of zones
+func (g *positionGuard) logPrivacy() {
+	if g.policy.Active() {
+		log.Printf("Privacy: %s", g.policy)
+	}
+	if len(g.zones) > 0 {
+		log.Printf("Private zones: %d", len(g.zones))
+	}
+}
+
+// isZoneSkip reports whether the error is a position skipped in a zone
+func isZoneSkip(err error) bool {
+	var skip *zoneSkipError
+	return errors.As(err, &skip)
+}
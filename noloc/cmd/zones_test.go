@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"noloc/location"
+)
+
+const testZones = `
+zones:
+  default:
+    - name: clinic
+      center: 60.2000,24.9000
+      radius: 100
+  alice:
+    - name: home
+      center: 60.1699,24.9384
+      radius: 200
+      action: coarse
+    - name: office
+      polygon: ["60.170,24.940", "60.171,24.945", "60.168,24.946"]
+      action: placeholder
+      label: At work
+      precision: 4
+privacy:
+  bob:
+    precision: 3
+`
+
+func TestZoneConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config zoneConfig
+		want   location.Zone
+		err    string
+	}{
+		{
+			name:   "circle",
+			config: zoneConfig{Name: "home", Center: "60.1699,24.9384", Radius: 300, Action: "coarse"},
+			want:   location.Zone{Name: "home", Lat: 60.1699, Lon: 24.9384, Radius: 300, Action: location.ZoneCoarse},
+		},
+		{
+			name:   "skip by default",
+			config: zoneConfig{Name: "home", Center: "geo:60.1699,24.9384", Radius: 300},
+			want:   location.Zone{Name: "home", Lat: 60.1699, Lon: 24.9384, Radius: 300, Action: location.ZoneSkip},
+		},
+		{
+			name:   "polygon",
+			config: zoneConfig{Name: "park", Polygon: []string{"0,0", "0,1", "1,1"}, Action: "placeholder", Label: "Out", Precision: 5},
+			want:   location.Zone{Name: "park", Polygon: [][2]float64{{0, 0}, {0, 1}, {1, 1}}, Action: location.ZonePlaceholder, Label: "Out", Precision: 5},
+		},
+		{name: "no area", config: zoneConfig{Name: "x", Radius: 100}, err: "needs a center or a polygon"},
+		{name: "no radius", config: zoneConfig{Name: "x", Center: "1,2"}, err: "needs a radius or a polygon"},
+		{name: "bad center", config: zoneConfig{Name: "x", Center: "north", Radius: 100}, err: "zone x"},
+		{name: "bad vertex", config: zoneConfig{Name: "x", Polygon: []string{"0,0", "0,1", "91,1"}}, err: "zone x"},
+		{name: "bad action", config: zoneConfig{Name: "x", Center: "1,2", Radius: 100, Action: "hide"}, err: "invalid action"},
+		{name: "bad precision", config: zoneConfig{Name: "x", Center: "1,2", Radius: 100, Precision: 13}, err: "precision must be 0 to 12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone, err := tt.config.zone()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if zone.Name != tt.want.Name || zone.Lat != tt.want.Lat || zone.Lon != tt.want.Lon || zone.Radius != tt.want.Radius ||
+				zone.Action != tt.want.Action || zone.Label != tt.want.Label || zone.Precision != tt.want.Precision ||
+				len(zone.Polygon) != len(tt.want.Polygon) {
+				t.Errorf("zone = %+v, want %+v", zone, tt.want)
+			}
+			for i := range zone.Polygon {
+				if zone.Polygon[i] != tt.want.Polygon[i] {
+					t.Errorf("vertex %d = %v, want %v", i, zone.Polygon[i], tt.want.Polygon[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrivateZones(t *testing.T) {
+	testConfig(t, "")
+	writeConfig(t, testZones)
+
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{nil, []string{"clinic"}},
+		{[]string{""}, []string{"clinic"}},
+		{[]string{"carol"}, []string{"clinic"}},
+		{[]string{"alice"}, []string{"clinic", "home", "office"}},
+	}
+	for _, tt := range tests {
+		zones, err := privateZones(tt.names...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, zone := range zones {
+			names = append(names, zone.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("zones of %q = %v, want %v", tt.names, names, tt.want)
+		}
+	}
+
+	writeConfig(t, "zones:\n  alice:\n    - name: home\n      radius: 300\n")
+	if _, err := privateZones("alice"); err == nil || !strings.Contains(err.Error(), "invalid zones for alice") {
+		t.Errorf("err = %v, want invalid zones for alice", err)
+	}
+}
+
+func TestPositionGuard(t *testing.T) {
+	testConfig(t, "")
+	writeConfig(t, testZones)
+
+	details := func() *location.Location {
+		return &location.Location{Title: "Lunch", Summary: "With Bob", Extra: nostr.Tags{{"location", "Esplanadi 1"}}}
+	}
+	tests := []struct {
+		name      string
+		sender    string
+		receivers []string
+		lat, lon  float64
+		skip      bool
+		zone      string
+		geohash   string
+		title     string
+	}{
+		{name: "outside", sender: "alice", lat: 60.25, lon: 24.95, geohash: location.Encode(60.25, 24.95, 9), title: "Lunch"},
+		{name: "default zone", sender: "alice", lat: 60.2001, lon: 24.9001, skip: true, zone: "clinic"},
+		{name: "zone of another sender", sender: "carol", lat: 60.1699, lon: 24.9384, geohash: location.Encode(60.1699, 24.9384, 9), title: "Lunch"},
+		{name: "coarse", sender: "alice", lat: 60.1699, lon: 24.9384, zone: "home", geohash: "ud9wr3", title: "Lunch"},
+		{name: "coarse for a capped receiver", sender: "alice", receivers: []string{"bob"}, lat: 60.1699, lon: 24.9384, zone: "home", geohash: "ud9", title: "Lunch"},
+		{name: "placeholder", sender: "alice", lat: 60.1697, lon: 24.9437, zone: "office", geohash: "ud9w", title: "At work"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard, err := newPositionGuard(tt.sender, tt.receivers...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loc := details()
+			zone, err := guard.protect(loc, tt.lat, tt.lon, 0, 9)
+			if isZoneSkip(err) != tt.skip {
+				t.Fatalf("err = %v, want skip %v", err, tt.skip)
+			}
+			if (zone == nil && tt.zone != "") || (zone != nil && zone.Name != tt.zone) {
+				t.Fatalf("zone = %v, want %q", zone, tt.zone)
+			}
+			if tt.skip {
+				if !strings.Contains(err.Error(), "inside private zone clinic, not published") {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loc.Geohash != tt.geohash || loc.Title != tt.title {
+				t.Errorf("geohash %s, title %q, want %s, %q", loc.Geohash, loc.Title, tt.geohash, tt.title)
+			}
+			placeholder := zone != nil && zone.Action == location.ZonePlaceholder
+			if cleared := loc.Summary == "" && len(loc.Extra) == 0; cleared != placeholder {
+				t.Errorf("summary %q, extra %v, want cleared %v", loc.Summary, loc.Extra, placeholder)
+			}
+		})
+	}
+}
+
+func TestBTCMapIgnoresZones(t *testing.T) {
+	testConfig(t, "")
+	writeConfig(t, testZones)
+	sender := testIdentity("alice")
+	if err := saveIdentities(map[string]Identity{"alice": sender}); err != nil {
+		t.Fatal(err)
+	}
+	k.Set("sender", "@alice")
+	k.Set("precision", 9)
+	config, err := validateBTCMapConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A merchant inside the sender's home is not the sender's position
+	place := BTCMapPlace{ID: 1, Name: "Cafe", Lat: 60.1699, Lon: 24.9384, Address: "Esplanadi 1"}
+	event, err := createBTCMapLocationEvent(config, place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := location.ParsePublicEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc.Geohash != location.Encode(place.Lat, place.Lon, 9) || loc.Title != "Cafe" || loc.Get("location") != "Esplanadi 1" {
+		t.Errorf("location = %+v", loc)
+	}
+}
@@ -0,0 +1,163 @@
+package location
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// iso6709Pattern matches ISO 6709 strings such as "+60.1699+024.9384/",
+// "+6010.19+02456.30/" or "+601011+0245618+15CRSWGS_84/". Latitude has 2
+// integer degree digits, longitude 3, each optionally followed by minutes
+// and seconds digits and a decimal fraction.
+var iso6709Pattern = regexp.MustCompile(`^([+-]\d{2,6}(?:\.\d+)?)([+-]\d{3,7}(?:\.\d+)?)([+-]\d+(?:\.\d+)?)?(CRS[^/]*)?/?$`)
+
+// ParseCoordinates parses a position given as decimal "lat,lon", an ISO 6709
+// string or a geo URI (RFC 5870). The returned uncertainty is the u
+// parameter of a geo URI in meters, 0 when not given.
+func ParseCoordinates(input string) (lat, lon, uncertainty float64, err error) {
+	input = strings.TrimSpace(input)
+
+	switch {
+	case strings.HasPrefix(strings.ToLower(input), "geo:"):
+		lat, lon, uncertainty, err = parseGeoURI(input[len("geo:"):])
+	case iso6709Pattern.MatchString(input):
+		lat, lon, err = parseISO6709(input)
+	default:
+		lat, lon, err = parseDecimal(input)
+	}
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, 0, fmt.Errorf("coordinates out of range: %s", input)
+	}
+	return lat, lon, uncertainty, nil
+}
+
+func parseDecimal(input string) (float64, float64, error) {
+	parts := strings.Split(input, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid coordinates %q: expected lat,lon", input)
+	}
+
+	lat, err := parseFloat(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid latitude %q", parts[0])
+	}
+	lon, err := parseFloat(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid longitude %q", parts[1])
+	}
+	return lat, lon, nil
+}
+
+// parseGeoURI parses the part of a geo URI after "geo:", e.g.
+// "60.1699,24.9384,15;crs=wgs84;u=35"
+func parseGeoURI(uri string) (float64, float64, float64, error) {
+	params := strings.Split(uri, ";")
+
+	coords := strings.Split(params[0], ",")
+	if len(coords) != 2 && len(coords) != 3 {
+		return 0, 0, 0, fmt.Errorf("invalid geo URI %q: expected geo:lat,lon[,alt]", "geo:"+uri)
+	}
+	lat, latErr := parseFloat(coords[0])
+	lon, lonErr := parseFloat(coords[1])
+	if latErr != nil || lonErr != nil {
+		return 0, 0, 0, fmt.Errorf("invalid geo URI %q: coordinates are not numbers", "geo:"+uri)
+	}
+
+	var uncertainty float64
+	for _, param := range params[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		switch strings.ToLower(key) {
+		case "crs":
+			if !strings.EqualFold(value, "wgs84") {
+				return 0, 0, 0, fmt.Errorf("unsupported geo URI crs %q, only wgs84", value)
+			}
+		case "u":
+			u, err := parseFloat(value)
+			if err != nil || u < 0 {
+				return 0, 0, 0, fmt.Errorf("invalid geo URI uncertainty %q", value)
+			}
+			uncertainty = u
+		}
+	}
+
+	return lat, lon, uncertainty, nil
+}
+
+func parseISO6709(input string) (float64, float64, error) {
+	match := iso6709Pattern.FindStringSubmatch(input)
+	if match[4] != "" && !strings.Contains(strings.ToUpper(match[4]), "WGS") {
+		return 0, 0, fmt.Errorf("unsupported ISO 6709 coordinate reference system %q", match[4])
+	}
+
+	lat, err := parseISO6709Component(match[1], 2)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ISO 6709 latitude %q: %w", match[1], err)
+	}
+	lon, err := parseISO6709Component(match[2], 3)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ISO 6709 longitude %q: %w", match[2], err)
+	}
+	return lat, lon, nil
+}
+
+// parseISO6709Component converts a signed ±D, ±DM or ±DMS value with the
+// given number of degree digits to decimal degrees
+func parseISO6709Component(value string, degreeDigits int) (float64, error) {
+	sign := 1.0
+	if value[0] == '-' {
+		sign = -1
+	}
+	value = value[1:]
+
+	integer, fraction, _ := strings.Cut(value, ".")
+	if fraction != "" {
+		fraction = "." + fraction
+	}
+
+	var degrees, minutes, seconds float64
+	switch len(integer) {
+	case degreeDigits:
+		degrees, _ = strconv.ParseFloat(integer+fraction, 64)
+	case degreeDigits + 2:
+		degrees, _ = strconv.ParseFloat(integer[:degreeDigits], 64)
+		minutes, _ = strconv.ParseFloat(integer[degreeDigits:]+fraction, 64)
+	case degreeDigits + 4:
+		degrees, _ = strconv.ParseFloat(integer[:degreeDigits], 64)
+		minutes, _ = strconv.ParseFloat(integer[degreeDigits:degreeDigits+2], 64)
+		seconds, _ = strconv.ParseFloat(integer[degreeDigits+2:]+fraction, 64)
+	default:
+		return 0, fmt.Errorf("expected %d, %d or %d integer digits", degreeDigits, degreeDigits+2, degreeDigits+4)
+	}
+
+	if minutes >= 60 || seconds >= 60 {
+		return 0, fmt.Errorf("minutes and seconds must be below 60")
+	}
+	return sign * (degrees + minutes/60 + seconds/3600), nil
+}
+
+func parseFloat(value string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return v, nil
+}
+
+// PrecisionForAccuracy returns the shortest geohash precision at the point
+// whose cell half-diagonal does not exceed the accuracy, so that encoding
+// adds no more error than the position already has
+func PrecisionForAccuracy(lat, lon, accuracy float64) int {
+	for precision := 1; precision < 12; precision++ {
+		if GeohashError(Encode(lat, lon, precision)) <= accuracy {
+			return precision
+		}
+	}
+	return 12
+}
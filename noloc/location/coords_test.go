@@ -0,0 +1,122 @@
+package location
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseCoordinates(t *testing.T) {
+	tests := []struct {
+		input            string
+		lat, lon, uncert float64
+		wantErr          bool
+	}{
+		{input: "60.1699,24.9384", lat: 60.1699, lon: 24.9384},
+		{input: " -33.8688, 151.2093 ", lat: -33.8688, lon: 151.2093},
+		{input: "-90,-180", lat: -90, lon: -180},
+		{input: "90,180", lat: 90, lon: 180},
+		{input: "geo:60.1699,24.9384", lat: 60.1699, lon: 24.9384},
+		{input: "GEO:60.1699,24.9384", lat: 60.1699, lon: 24.9384},
+		{input: "geo:60.1699,24.9384,15;crs=wgs84;u=35", lat: 60.1699, lon: 24.9384, uncert: 35},
+		{input: "+60.1699+024.9384/", lat: 60.1699, lon: 24.9384},
+		{input: "+60.1699+024.9384", lat: 60.1699, lon: 24.9384},
+		{input: "+6010.19+02456.30/", lat: 60 + 10.19/60, lon: 24 + 56.30/60},
+		{input: "+6010.19+02456.30", lat: 60 + 10.19/60, lon: 24 + 56.30/60},
+		{input: "+601011+0245618+15CRSWGS_84/", lat: 60 + 10.0/60 + 11.0/3600, lon: 24 + 56.0/60 + 18.0/3600},
+		{input: "-3352.5+15112.5/", lat: -(33 + 52.5/60), lon: 151 + 12.5/60},
+
+		{input: "91,0", wantErr: true},
+		{input: "-90.5,0", wantErr: true},
+		{input: "0,180.1", wantErr: true},
+		{input: "0,-181", wantErr: true},
+		{input: "geo:91,0", wantErr: true},
+		{input: "+91.0+024.0/", wantErr: true},
+		{input: "+60.0+181.0/", wantErr: true},
+		{input: "+6070+02456/", wantErr: true},
+		{input: "+601075+0245618/", wantErr: true},
+		{input: "+60.1699+024.9384CRSEPSG_4277/", wantErr: true},
+		{input: "geo:60,24;crs=nad27", wantErr: true},
+		{input: "geo:60,24;u=-1", wantErr: true},
+		{input: "geo:60", wantErr: true},
+		{input: "NaN,0", wantErr: true},
+		{input: "0,Inf", wantErr: true},
+		{input: "1,2,3", wantErr: true},
+		{input: "helsinki", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			lat, lon, uncertainty, err := ParseCoordinates(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, %v, want error", lat, lon)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(lat-tt.lat) > 1e-9 || math.Abs(lon-tt.lon) > 1e-9 || uncertainty != tt.uncert {
+				t.Errorf("got %v, %v, %v, want %v, %v, %v", lat, lon, uncertainty, tt.lat, tt.lon, tt.uncert)
+			}
+		})
+	}
+}
+
+func TestValidGeohash(t *testing.T) {
+	tests := []struct {
+		geohash string
+		want    bool
+	}{
+		{"u", true},
+		{"u4pruydqqvj", true},
+		{"u4pruydqqvjx", true},
+		{"", false},
+		{"u4pruydqqvjxy", false},
+		{"U4PRUYD", false},
+		{"u4pA", false},
+		{"u4pa", false},
+		{"u4pi", false},
+		{"u4pl", false},
+		{"u4po", false},
+		{"u4p ", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidGeohash(tt.geohash); got != tt.want {
+			t.Errorf("ValidGeohash(%q) = %v, want %v", tt.geohash, got, tt.want)
+		}
+	}
+}
+
+func TestPrecisionForAccuracy(t *testing.T) {
+	tests := []struct {
+		lat, lon, accuracy float64
+		want               int
+	}{
+		{60.1699, 24.9384, 1e7, 1},
+		{0, 0, math.Inf(1), 1},
+		{60.1699, 24.9384, 0, 12},
+		{60.1699, 24.9384, -1, 12},
+		{60.1699, 24.9384, 0.001, 12},
+		{89.9, 179.9, 0, 12},
+	}
+
+	for _, tt := range tests {
+		if got := PrecisionForAccuracy(tt.lat, tt.lon, tt.accuracy); got != tt.want {
+			t.Errorf("PrecisionForAccuracy(%v, %v, %v) = %d, want %d", tt.lat, tt.lon, tt.accuracy, got, tt.want)
+		}
+	}
+
+	// In between, the shortest precision whose error fits the accuracy
+	for _, accuracy := range []float64{5, 20, 100, 1000, 25000} {
+		precision := PrecisionForAccuracy(60.1699, 24.9384, accuracy)
+		if GeohashError(Encode(60.1699, 24.9384, precision)) > accuracy {
+			t.Errorf("accuracy %v: precision %d adds more error than the accuracy", accuracy, precision)
+		}
+		if precision > 1 && GeohashError(Encode(60.1699, 24.9384, precision-1)) <= accuracy {
+			t.Errorf("accuracy %v: precision %d is longer than needed", accuracy, precision)
+		}
+	}
+}
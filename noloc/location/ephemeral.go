@@ -0,0 +1,34 @@
+package location
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// ephemeralKeyLabel separates ephemeral sender keys from other uses of a seed
+const ephemeralKeyLabel = "noloc ephemeral sender"
+
+// Epoch returns the rotation epoch of a time, the number of whole rotation
+// intervals since the Unix epoch
+func Epoch(t time.Time, rotation time.Duration) int64 {
+	seconds := int64(rotation / time.Second)
+	if seconds <= 0 {
+		seconds = 1
+	}
+	return t.Unix() / seconds
+}
+
+// EphemeralKey derives the hex private key of an epoch from a seed shared
+// by sender and receiver, as HMAC-SHA256(seed, label || uint64be(epoch)).
+// The receiver derives the same keys to know which authors to follow.
+func EphemeralKey(seed []byte, epoch int64) string {
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(ephemeralKeyLabel))
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(epoch))
+	mac.Write(counter[:])
+	return hex.EncodeToString(mac.Sum(nil))
+}
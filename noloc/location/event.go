@@ -0,0 +1,265 @@
+package location
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip44"
+)
+
+// EventOptions holds the event level settings shared by both kinds
+type EventOptions struct {
+	D         string        // d tag identifier, empty for single location per pubkey
+	TTL       time.Duration // Adds NIP-40 expiration relative to now, 0 = no expiration
+	CreatedAt time.Time     // Event timestamp, zero = now
+	Anon      bool          // Omit p tag from private events
+}
+
+func (o EventOptions) baseTags() nostr.Tags {
+	tags := nostr.Tags{{"d", o.D}}
+	if o.TTL > 0 {
+		expiration := time.Now().Add(o.TTL).Unix()
+		tags = append(tags, nostr.Tag{"expiration", fmt.Sprintf("%d", expiration)})
+	}
+	return tags
+}
+
+func (o EventOptions) createdAt() nostr.Timestamp {
+	if o.CreatedAt.IsZero() {
+		return nostr.Now()
+	}
+	return nostr.Timestamp(o.CreatedAt.Unix())
+}
+
+// BuildPublicEvent creates a signed kind 30472 event with location tags and
+// empty content
+func BuildPublicEvent(senderSK string, loc *Location, opts EventOptions) (*nostr.Event, error) {
+	signer, err := keyer.NewPlainKeySigner(senderSK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender public key: %w", err)
+	}
+	return SignPublicEvent(context.Background(), signer, loc, opts)
+}
+
+// SignPublicEvent creates a kind 30472 event signed by any signer, such as
+// a NIP-46 remote signer
+func SignPublicEvent(ctx context.Context, signer nostr.Signer, loc *Location, opts EventOptions) (*nostr.Event, error) {
+	if loc.Geohash == "" {
+		return nil, fmt.Errorf("geohash is required")
+	}
+
+	senderPubkey, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender public key: %w", err)
+	}
+
+	// Shorter geohash prefixes as extra g tags let relays match #g filters
+	// at any precision
+	locTags := loc.Tags()
+	tags := nostr.Tags{locTags[0]}
+	for _, prefix := range Prefixes(loc.Geohash) {
+		tags = append(tags, nostr.Tag{"g", prefix})
+	}
+	tags = append(tags, opts.baseTags()...)
+	tags = append(tags, locTags[1:]...)
+
+	event := &nostr.Event{
+		PubKey:    senderPubkey,
+		CreatedAt: opts.createdAt(),
+		Kind:      KindPublic,
+		Tags:      tags,
+		Content:   "", // No content field for public events
+	}
+
+	if err := signer.SignEvent(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to sign event: %w", err)
+	}
+
+	return event, nil
+}
+
+// BuildPrivateEvent creates a signed kind 30473 event with location tags
+// NIP-44 encrypted for the receiver
+func BuildPrivateEvent(senderSK, receiverPubkey string, loc *Location, opts EventOptions) (*nostr.Event, error) {
+	signer, err := keyer.NewPlainKeySigner(senderSK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender public key: %w", err)
+	}
+	return SignPrivateEvent(context.Background(), signer, receiverPubkey, loc, opts)
+}
+
+// SignPrivateEvent creates a kind 30473 event encrypted and signed by any
+// signer, such as a NIP-46 remote signer
+func SignPrivateEvent(ctx context.Context, signer nostr.Keyer, receiverPubkey string, loc *Location, opts EventOptions) (*nostr.Event, error) {
+	event, err := privateEvent(ctx, signer, receiverPubkey, loc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := signer.SignEvent(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to sign event: %w", err)
+	}
+
+	return event, nil
+}
+
+// privateEvent creates an unsigned kind 30473 event with the location
+// encrypted for the receiver
+func privateEvent(ctx context.Context, signer nostr.Keyer, receiverPubkey string, loc *Location, opts EventOptions) (*nostr.Event, error) {
+	if loc.Geohash == "" {
+		return nil, fmt.Errorf("geohash is required")
+	}
+
+	senderPubkey, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender public key: %w", err)
+	}
+
+	content, err := EncryptContentWith(ctx, signer, loc, receiverPubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := opts.baseTags()
+	// Only add p-tag if not anonymous
+	if !opts.Anon {
+		tags = append(nostr.Tags{{"p", receiverPubkey}}, tags...)
+	}
+
+	event := &nostr.Event{
+		PubKey:    senderPubkey,
+		CreatedAt: opts.createdAt(),
+		Kind:      KindPrivate,
+		Tags:      tags,
+		Content:   content,
+	}
+	event.ID = event.GetID()
+
+	return event, nil
+}
+
+// EncryptContent encrypts location tags as a JSON tag array with NIP-44
+func EncryptContent(loc *Location, senderSK, receiverPubkey string) (string, error) {
+	locationJSON, err := json.Marshal(loc.Tags())
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal location data: %w", err)
+	}
+
+	conversationKey, err := nip44.GenerateConversationKey(receiverPubkey, senderSK)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate conversation key: %w", err)
+	}
+
+	encryptedContent, err := nip44.Encrypt(string(locationJSON), conversationKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt content: %w", err)
+	}
+
+	return encryptedContent, nil
+}
+
+// EncryptContentWith encrypts location tags with the NIP-44 implementation
+// of a signer
+func EncryptContentWith(ctx context.Context, cipher nostr.Cipher, loc *Location, receiverPubkey string) (string, error) {
+	locationJSON, err := json.Marshal(loc.Tags())
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal location data: %w", err)
+	}
+
+	encryptedContent, err := cipher.Encrypt(ctx, string(locationJSON), receiverPubkey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt content: %w", err)
+	}
+
+	return encryptedContent, nil
+}
+
+// DecryptContent decrypts NIP-44 content into the raw location tag array
+func DecryptContent(encryptedContent, receiverSK, senderPubkey string) (nostr.Tags, error) {
+	conversationKey, err := nip44.GenerateConversationKey(senderPubkey, receiverSK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate conversation key: %w", err)
+	}
+
+	decryptedContent, err := nip44.Decrypt(encryptedContent, conversationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt content: %w", err)
+	}
+
+	return UnmarshalTags(decryptedContent)
+}
+
+// DecryptContentWith decrypts NIP-44 content with the NIP-44 implementation
+// of a signer
+func DecryptContentWith(ctx context.Context, cipher nostr.Cipher, encryptedContent, senderPubkey string) (nostr.Tags, error) {
+	decryptedContent, err := cipher.Decrypt(ctx, encryptedContent, senderPubkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt content: %w", err)
+	}
+
+	return UnmarshalTags(decryptedContent)
+}
+
+// UnmarshalTags parses a JSON tag array. Non-string tag items are converted
+// to their string form.
+func UnmarshalTags(data string) (nostr.Tags, error) {
+	var raw [][]interface{}
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal location data: %w", err)
+	}
+
+	tags := make(nostr.Tags, 0, len(raw))
+	for _, item := range raw {
+		tag := make(nostr.Tag, len(item))
+		for i, v := range item {
+			if s, ok := v.(string); ok {
+				tag[i] = s
+			} else {
+				tag[i] = fmt.Sprintf("%v", v)
+			}
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// ParsePublicEvent reads the location from a kind 30472 event
+func ParsePublicEvent(event *nostr.Event) (*Location, error) {
+	if event.Kind != KindPublic {
+		return nil, fmt.Errorf("unexpected kind %d, expected %d", event.Kind, KindPublic)
+	}
+	return FromTags(event.Tags)
+}
+
+// ParsePrivateEvent decrypts and reads the location from a kind 30473 event
+func ParsePrivateEvent(event *nostr.Event, receiverSK string) (*Location, error) {
+	if event.Kind != KindPrivate {
+		return nil, fmt.Errorf("unexpected kind %d, expected %d", event.Kind, KindPrivate)
+	}
+
+	tags, err := DecryptContent(event.Content, receiverSK, event.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromTags(tags)
+}
+
+// Expiration returns the NIP-40 expiration of an event, zero if not set
+func Expiration(event *nostr.Event) time.Time {
+	tag := event.Tags.Find("expiration")
+	if tag == nil {
+		return time.Time{}
+	}
+	ts, err := strconv.ParseInt(tag[1], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
@@ -0,0 +1,149 @@
+package location
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestPublicEventRoundTrip(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pubkey, _ := nostr.GetPublicKey(sk)
+	created := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name string
+		loc  Location
+		opts EventOptions
+	}{
+		{"minimal", Location{Geohash: "u4pruydqqvj"}, EventOptions{}},
+		{"with d-tag and time", Location{Geohash: "ud9wr3", Accuracy: 20, Title: "Office"}, EventOptions{D: "office", CreatedAt: created}},
+		{"hashtags and extra", Location{Geohash: "u4pr", Hashtags: []string{"test"}, Extra: nostr.Tags{{"name", "ISS"}}}, EventOptions{D: "iss"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := BuildPublicEvent(sk, &tt.loc, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Kind != KindPublic || event.Content != "" || event.PubKey != pubkey {
+				t.Errorf("event kind %d by %s with content %q", event.Kind, event.PubKey, event.Content)
+			}
+			if ok, err := event.CheckSignature(); !ok || err != nil {
+				t.Errorf("invalid signature: %v", err)
+			}
+			if d := event.Tags.GetD(); d != tt.opts.D {
+				t.Errorf("d-tag = %q, want %q", d, tt.opts.D)
+			}
+			if !tt.opts.CreatedAt.IsZero() && !event.CreatedAt.Time().Equal(tt.opts.CreatedAt) {
+				t.Errorf("created_at = %s, want %s", event.CreatedAt.Time(), tt.opts.CreatedAt)
+			}
+
+			got, err := ParsePublicEvent(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.loc) {
+				t.Errorf("parsed %+v, want %+v", *got, tt.loc)
+			}
+		})
+	}
+
+	if _, err := BuildPublicEvent(sk, &Location{}, EventOptions{}); err == nil {
+		t.Error("want an error without geohash")
+	}
+	if _, err := ParsePublicEvent(&nostr.Event{Kind: KindPrivate, Tags: nostr.Tags{{"g", "u4pr"}}}); err == nil {
+		t.Error("want an error for a private kind")
+	}
+}
+
+func TestPrivateEventRoundTrip(t *testing.T) {
+	senderSK := nostr.GeneratePrivateKey()
+	receiverSK := nostr.GeneratePrivateKey()
+	receiverPubkey, _ := nostr.GetPublicKey(receiverSK)
+	loc := Location{Geohash: "ud9wr3x", Accuracy: 35, Title: "Home", Extra: nostr.Tags{{"name", "Alice"}}}
+
+	tests := []struct {
+		name string
+		opts EventOptions
+		pTag bool
+	}{
+		{"addressed", EventOptions{D: "home"}, true},
+		{"anonymous", EventOptions{D: "home", Anon: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := BuildPrivateEvent(senderSK, receiverPubkey, &loc, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Kind != KindPrivate {
+				t.Errorf("kind = %d, want %d", event.Kind, KindPrivate)
+			}
+			if ok, err := event.CheckSignature(); !ok || err != nil {
+				t.Errorf("invalid signature: %v", err)
+			}
+			for _, key := range []string{"g", "accuracy", "title", "name"} {
+				if event.Tags.Find(key) != nil {
+					t.Errorf("location tag %q is public", key)
+				}
+			}
+			if p := event.Tags.Find("p"); (p != nil) != tt.pTag || (p != nil && p[1] != receiverPubkey) {
+				t.Errorf("p-tag = %v, want present %v", p, tt.pTag)
+			}
+
+			got, err := ParsePrivateEvent(event, receiverSK)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, loc) {
+				t.Errorf("parsed %+v, want %+v", *got, loc)
+			}
+
+			if _, err := ParsePrivateEvent(event, nostr.GeneratePrivateKey()); err == nil {
+				t.Error("decrypted by another key")
+			}
+		})
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	loc := &Location{Geohash: "u4pr"}
+
+	event, err := BuildPublicEvent(sk, loc, EventOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := Expiration(event); !exp.IsZero() {
+		t.Errorf("expiration = %s without a TTL", exp)
+	}
+
+	before := time.Now()
+	event, err = BuildPublicEvent(sk, loc, EventOptions{TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := Expiration(event)
+	if exp.Before(before.Add(time.Hour).Truncate(time.Second)) || exp.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expiration = %s, want an hour from now", exp)
+	}
+
+	tests := []struct {
+		tags nostr.Tags
+		want time.Time
+	}{
+		{nostr.Tags{{"expiration", "1700000000"}}, time.Unix(1700000000, 0)},
+		{nostr.Tags{{"expiration", "soon"}}, time.Time{}},
+		{nil, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := Expiration(&nostr.Event{Tags: tt.tags}); !got.Equal(tt.want) {
+			t.Errorf("Expiration(%v) = %s, want %s", tt.tags, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package location
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+// earthRadius is the mean Earth radius in meters
+const earthRadius = 6371008.8
+
+// DefaultMaxCells limits the number of geohash prefixes in a cover set
+const DefaultMaxCells = 32
+
+// edgeMargin keeps points on the north pole and the antimeridian inside the
+// last cells, in degrees
+const edgeMargin = 1e-7
+
+// BoundingBox is a latitude/longitude rectangle in degrees. MinLon greater
+// than MaxLon means the box crosses the antimeridian.
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+// GeohashBox returns the cell of a geohash
+func GeohashBox(gh string) BoundingBox {
+	box := geohash.BoundingBox(gh)
+	return BoundingBox{MinLat: box.MinLat, MinLon: box.MinLng, MaxLat: box.MaxLat, MaxLon: box.MaxLng}
+}
+
+// Center returns the midpoint of the box
+func (b BoundingBox) Center() (float64, float64) {
+	lon := (b.MinLon + b.MaxLon) / 2
+	if b.MinLon > b.MaxLon {
+		lon = normalizeLon(lon + 180)
+	}
+	return (b.MinLat + b.MaxLat) / 2, lon
+}
+
+// Contains reports whether the point lies inside the box
+func (b BoundingBox) Contains(lat, lon float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return lon >= b.MinLon && lon <= b.MaxLon
+	}
+	return lon >= b.MinLon || lon <= b.MaxLon
+}
+
+// Intersects reports whether the boxes share at least one point
+func (b BoundingBox) Intersects(other BoundingBox) bool {
+	if b.MaxLat < other.MinLat || other.MaxLat < b.MinLat {
+		return false
+	}
+	for _, r := range b.lonRanges() {
+		for _, o := range other.lonRanges() {
+			if r[0] <= o[1] && o[0] <= r[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Nearest returns the point of the box closest to the given point. Latitude
+// and longitude are clamped separately, which is close enough for boxes
+// much smaller than a hemisphere.
+func (b BoundingBox) Nearest(lat, lon float64) (float64, float64) {
+	lat = math.Max(b.MinLat, math.Min(b.MaxLat, lat))
+	if b.Contains(lat, lon) {
+		return lat, lon
+	}
+	if lonDelta(lon, b.MinLon) <= lonDelta(lon, b.MaxLon) {
+		return lat, b.MinLon
+	}
+	return lat, b.MaxLon
+}
+
+// GeohashError returns the half-diagonal of a geohash cell in meters, the
+// largest distance from the decoded center to any point of the cell
+func GeohashError(gh string) float64 {
+	box := GeohashBox(gh)
+	lat, lon := box.Center()
+	// The edge closer to the equator is the wider one
+	return math.Max(
+		Distance(lat, lon, box.MinLat, box.MaxLon),
+		Distance(lat, lon, box.MaxLat, box.MaxLon),
+	)
+}
+
+// Uncertainty returns the radius in meters around the geohash center that
+// holds the true location with 68% confidence, like the accuracy tag. A
+// point spread evenly over the cell has an RMS distance of half-diagonal/√3
+// from the center, which is combined with the sender's accuracy as
+// independent errors. Accuracy 0 means only the geohash cell is known.
+func Uncertainty(gh string, accuracy float64) float64 {
+	cell := GeohashError(gh) / math.Sqrt(3)
+	return math.Sqrt(cell*cell + accuracy*accuracy)
+}
+
+// Distance returns the haversine great-circle distance in meters
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// CircleBox returns the bounding box of a circle around a point
+func CircleBox(lat, lon, radius float64) BoundingBox {
+	dLat := radius / earthRadius * 180 / math.Pi
+	box := BoundingBox{
+		MinLat: math.Max(-90, lat-dLat),
+		MaxLat: math.Min(90, lat+dLat),
+		MinLon: -180,
+		MaxLon: 180,
+	}
+
+	// Near the poles the circle covers every longitude
+	cosLat := math.Cos(lat * math.Pi / 180)
+	if box.MinLat > -90 && box.MaxLat < 90 && cosLat > 0 {
+		dLon := dLat / cosLat
+		if dLon < 180 {
+			box.MinLon = normalizeLon(lon - dLon)
+			box.MaxLon = normalizeLon(lon + dLon)
+		}
+	}
+
+	return box
+}
+
+// CoverBox returns the geohash prefixes of the highest precision that cover
+// the box with at most maxCells cells
+func CoverBox(box BoundingBox, maxCells int) []string {
+	if maxCells <= 0 {
+		maxCells = DefaultMaxCells
+	}
+
+	var cells []string
+	for precision := 1; precision <= 12; precision++ {
+		if estimateCells(box, precision) > maxCells {
+			break
+		}
+		cells = coverWithPrecision(box, precision)
+	}
+
+	// Even single characters exceed the limit, fall back to them anyway
+	if cells == nil {
+		cells = coverWithPrecision(box, 1)
+	}
+
+	return cells
+}
+
+// CoverCircle returns geohash prefixes covering a circle around a point
+func CoverCircle(lat, lon, radius float64, maxCells int) []string {
+	return CoverBox(CircleBox(lat, lon, radius), maxCells)
+}
+
+// Prefixes returns all proper prefixes of a geohash, shortest first
+func Prefixes(gh string) []string {
+	prefixes := make([]string, 0, len(gh))
+	for i := 1; i < len(gh); i++ {
+		prefixes = append(prefixes, gh[:i])
+	}
+	return prefixes
+}
+
+// cellSize returns the height and width in degrees of a geohash cell
+func cellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lonBits))
+}
+
+func (b BoundingBox) lonRanges() [][2]float64 {
+	if b.MinLon <= b.MaxLon {
+		return [][2]float64{{b.MinLon, b.MaxLon}}
+	}
+	return [][2]float64{{b.MinLon, 180}, {-180, b.MaxLon}}
+}
+
+func estimateCells(box BoundingBox, precision int) int {
+	height, width := cellSize(precision)
+	rows := int(math.Ceil((box.MaxLat-box.MinLat)/height)) + 1
+	cols := 0
+	for _, r := range box.lonRanges() {
+		cols += int(math.Ceil((r[1]-r[0])/width)) + 1
+	}
+	return rows * cols
+}
+
+func coverWithPrecision(box BoundingBox, precision int) []string {
+	height, width := cellSize(precision)
+
+	seen := make(map[string]bool)
+	for _, lat := range samples(box.MinLat, box.MaxLat, height) {
+		for _, r := range box.lonRanges() {
+			for _, lon := range samples(r[0], r[1], width) {
+				// The upper edges would wrap around to the lower ones.
+				// The encoder quantizes to 32 bits, so the margin must be
+				// above its step and below the smallest cell.
+				lat := math.Min(lat, 90-edgeMargin)
+				lon := math.Min(lon, 180-edgeMargin)
+				seen[geohash.EncodeWithPrecision(lat, lon, uint(precision))] = true
+			}
+		}
+	}
+
+	cells := make([]string, 0, len(seen))
+	for cell := range seen {
+		cells = append(cells, cell)
+	}
+	sort.Strings(cells)
+	return cells
+}
+
+// samples steps through a range so that every cell of the given size
+// intersecting it contains at least one sample
+func samples(min, max, step float64) []float64 {
+	var values []float64
+	for v := min; v < max; v += step {
+		values = append(values, v)
+	}
+	return append(values, max)
+}
+
+// lonDelta returns the angle between two longitudes, at most 180 degrees
+func lonDelta(a, b float64) float64 {
+	return math.Abs(normalizeLon(a - b))
+}
+
+func normalizeLon(lon float64) float64 {
+	for lon > 180 {
+		lon -= 360
+	}
+	for lon < -180 {
+		lon += 360
+	}
+	return lon
+}
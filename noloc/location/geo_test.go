@@ -0,0 +1,235 @@
+package location
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mmcloughlin/geohash"
+)
+
+var (
+	helsinki     = BoundingBox{MinLat: 60.1, MinLon: 24.8, MaxLat: 60.3, MaxLon: 25.1}
+	antimeridian = BoundingBox{MinLat: -18, MinLon: 179, MaxLat: -17, MaxLon: -179}
+)
+
+// covers checks that every point of a grid over the box lies in one of the
+// cells
+func covers(t *testing.T, box BoundingBox, cells []string) {
+	t.Helper()
+	for _, r := range box.lonRanges() {
+		for i := 0; i <= 20; i++ {
+			for j := 0; j <= 20; j++ {
+				lat := box.MinLat + (box.MaxLat-box.MinLat)*float64(i)/20
+				lon := r[0] + (r[1]-r[0])*float64(j)/20
+				if lon == 180 {
+					lon = -180
+				}
+				gh := geohash.EncodeWithPrecision(lat, lon, 12)
+				if !slices.ContainsFunc(cells, func(cell string) bool { return gh[:len(cell)] == cell }) {
+					t.Fatalf("%v,%v (%s) is not covered by %v", lat, lon, gh, cells)
+				}
+			}
+		}
+	}
+}
+
+func TestCoverBox(t *testing.T) {
+	tests := []struct {
+		name      string
+		box       BoundingBox
+		maxCells  int
+		want      []string // nil = only check the cover
+		precision int
+	}{
+		{"helsinki", helsinki, 32, []string{"ud9t", "ud9v", "ud9w", "ud9x", "ud9y", "ud9z"}, 4},
+		{"helsinki default limit", helsinki, 0, []string{"ud9t", "ud9v", "ud9w", "ud9x", "ud9y", "ud9z"}, 4},
+		{"helsinki few cells", helsinki, 4, []string{"ud9"}, 3},
+		{"point", BoundingBox{MinLat: 60.1699, MinLon: 24.9384, MaxLat: 60.1699, MaxLon: 24.9384}, 1, []string{"ud9wr3xe4f6f"}, 12},
+		{"antimeridian", antimeridian, 32, nil, 3},
+		{"world", BoundingBox{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180}, 32, nil, 1},
+		{"world over the limit", BoundingBox{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180}, 4, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cells := CoverBox(tt.box, tt.maxCells)
+			if tt.want != nil && !slices.Equal(cells, tt.want) {
+				t.Errorf("cells = %v, want %v", cells, tt.want)
+			}
+			for _, cell := range cells {
+				if len(cell) != tt.precision {
+					t.Errorf("cell %s, want precision %d", cell, tt.precision)
+				}
+			}
+			covers(t, tt.box, cells)
+		})
+	}
+}
+
+func TestCoverBoxAntimeridian(t *testing.T) {
+	cells := CoverBox(antimeridian, 32)
+	var east, west bool
+	for _, cell := range cells {
+		_, lon := geohash.Decode(cell)
+		east = east || lon > 0
+		west = west || lon < 0
+	}
+	if !east || !west {
+		t.Errorf("cells %v do not cover both sides of the antimeridian", cells)
+	}
+}
+
+func TestEstimateCells(t *testing.T) {
+	for _, box := range []BoundingBox{helsinki, antimeridian, {MinLat: 0, MinLon: 0, MaxLat: 0, MaxLon: 0}} {
+		for precision := 1; precision <= 6; precision++ {
+			estimate := estimateCells(box, precision)
+			cells := coverWithPrecision(box, precision)
+			// CoverBox relies on the estimate never being below the cover
+			if estimate < len(cells) {
+				t.Errorf("%+v precision %d: estimate %d, cover %d cells", box, precision, estimate, len(cells))
+			}
+		}
+	}
+
+	if got := estimateCells(BoundingBox{}, 12); got != 1 {
+		t.Errorf("point estimate = %d, want 1", got)
+	}
+}
+
+func TestCoverWithPrecision(t *testing.T) {
+	for precision := 1; precision <= 5; precision++ {
+		cells := coverWithPrecision(helsinki, precision)
+		if !slices.IsSorted(cells) || len(slices.Compact(slices.Clone(cells))) != len(cells) {
+			t.Errorf("precision %d: cells %v not sorted and unique", precision, cells)
+		}
+		for _, cell := range cells {
+			if !GeohashBox(cell).Intersects(helsinki) {
+				t.Errorf("precision %d: cell %s outside the box", precision, cell)
+			}
+		}
+		covers(t, helsinki, cells)
+	}
+
+	// The north pole and the antimeridian stay in the cells on the edge
+	for precision := 1; precision <= 12; precision++ {
+		edge := coverWithPrecision(BoundingBox{MinLat: 90, MinLon: 180, MaxLat: 90, MaxLon: 180}, precision)
+		if want := strings.Repeat("z", precision); !slices.Equal(edge, []string{want}) {
+			t.Errorf("edge cells = %v, want [%s]", edge, want)
+		}
+	}
+}
+
+func TestBoundingBoxIntersects(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b BoundingBox
+		want bool
+	}{
+		{"inside", helsinki, GeohashBox("ud9wr"), true},
+		{"containing", GeohashBox("u"), helsinki, true},
+		{"partly", helsinki, BoundingBox{MinLat: 60.2, MinLon: 25, MaxLat: 61, MaxLon: 26}, true},
+		{"touching", helsinki, BoundingBox{MinLat: 60.3, MinLon: 25.1, MaxLat: 61, MaxLon: 26}, true},
+		{"north", helsinki, BoundingBox{MinLat: 61, MinLon: 24.8, MaxLat: 62, MaxLon: 25.1}, false},
+		{"east", helsinki, BoundingBox{MinLat: 60.1, MinLon: 26, MaxLat: 60.3, MaxLon: 27}, false},
+		{"across the antimeridian", antimeridian, BoundingBox{MinLat: -17.5, MinLon: -179.5, MaxLat: -17.4, MaxLon: -179.4}, true},
+		{"outside the antimeridian box", antimeridian, BoundingBox{MinLat: -17.5, MinLon: 0, MaxLat: -17.4, MaxLon: 1}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Intersects(tt.b); got != tt.want {
+			t.Errorf("%s: Intersects = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := tt.b.Intersects(tt.a); got != tt.want {
+			t.Errorf("%s reversed: Intersects = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	if got := Prefixes("u4pru"); !slices.Equal(got, []string{"u", "u4", "u4p", "u4pr"}) {
+		t.Errorf("Prefixes = %v", got)
+	}
+	if got := Prefixes("u"); len(got) != 0 {
+		t.Errorf("Prefixes of one character = %v", got)
+	}
+}
+
+func TestBoundingBoxNearest(t *testing.T) {
+	tests := []struct {
+		name             string
+		box              BoundingBox
+		lat, lon         float64
+		wantLat, wantLon float64
+	}{
+		{"inside", helsinki, 60.2, 25, 60.2, 25},
+		{"north", helsinki, 61, 25, 60.3, 25},
+		{"south west", helsinki, 59, 20, 60.1, 24.8},
+		{"east", helsinki, 60.2, 30, 60.2, 25.1},
+		{"across the antimeridian", antimeridian, -17.5, 170, -17.5, 179},
+		{"west of the antimeridian", antimeridian, -20, -170, -18, -179},
+		{"nearer over the antimeridian", BoundingBox{MinLat: 0, MinLon: 170, MaxLat: 1, MaxLon: 175}, 0.5, -178, 0.5, 175},
+	}
+
+	for _, tt := range tests {
+		lat, lon := tt.box.Nearest(tt.lat, tt.lon)
+		if lat != tt.wantLat || lon != tt.wantLon {
+			t.Errorf("%s: Nearest = %v, %v, want %v, %v", tt.name, lat, lon, tt.wantLat, tt.wantLon)
+		}
+	}
+}
+
+func TestGeohashError(t *testing.T) {
+	// Cell sizes at the equator, one degree is 111195 m
+	degree := earthRadius * math.Pi / 180
+	tests := []struct {
+		geohash string
+		want    float64 // Half-diagonal in meters
+	}{
+		{"s0000", math.Hypot(0.0439453125*degree, 0.0439453125*degree) / 2},       // 4.9 x 4.9 km
+		{"s00001", math.Hypot(0.0054931640625*degree, 0.010986328125*degree) / 2}, // 0.6 x 1.2 km
+		{"s000000", math.Hypot(0.001373291015625*degree, 0.001373291015625*degree) / 2},
+		// At 60° north the cell is half as wide
+		{"ud9wr3x", math.Hypot(0.001373291015625*degree, 0.001373291015625*degree*math.Cos(60.1694*math.Pi/180)) / 2},
+	}
+
+	for _, tt := range tests {
+		if got := GeohashError(tt.geohash); math.Abs(got-tt.want) > tt.want*0.001 {
+			t.Errorf("GeohashError(%q) = %.2f m, want %.2f m", tt.geohash, got, tt.want)
+		}
+	}
+
+	// Each character shrinks the error
+	for precision := 2; precision <= 12; precision++ {
+		if GeohashError(Encode(60.1699, 24.9384, precision)) >= GeohashError(Encode(60.1699, 24.9384, precision-1)) {
+			t.Errorf("error of precision %d is not smaller than of %d", precision, precision-1)
+		}
+	}
+}
+
+func TestUncertainty(t *testing.T) {
+	cell := GeohashError("s000000") / math.Sqrt(3)
+	tests := []struct {
+		geohash  string
+		accuracy float64
+		want     float64
+	}{
+		{"s000000", 0, cell},
+		{"s000000", 50, math.Sqrt(cell*cell + 50*50)},
+		{"s000000", cell, cell * math.Sqrt2},
+		// A 12 character cell adds next to nothing to the accuracy
+		{"s00000000000", 30, 30},
+	}
+
+	for _, tt := range tests {
+		if got := Uncertainty(tt.geohash, tt.accuracy); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("Uncertainty(%q, %v) = %.3f, want %.3f", tt.geohash, tt.accuracy, got, tt.want)
+		}
+	}
+
+	loc := &Location{Geohash: "s000000", Accuracy: 50}
+	if loc.Uncertainty() != Uncertainty("s000000", 50) {
+		t.Errorf("Location.Uncertainty = %v", loc.Uncertainty())
+	}
+}
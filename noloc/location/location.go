@@ -0,0 +1,148 @@
+// Package location builds and parses location-first Nostr events as defined
+// in doc/NostrLocation.md: public kind 30472 events carry location tags in
+// the event tags, private kind 30473 events carry them NIP-44 encrypted in
+// the content field.
+package location
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// KindPublic is the addressable kind for public location events
+	KindPublic = 30472
+	// KindPrivate is the addressable kind for encrypted location events
+	KindPrivate = 30473
+)
+
+// Location holds the location tags of an event. Geohash is required, all
+// other fields are optional.
+type Location struct {
+	Geohash  string     // "g" tag
+	Accuracy float64    // "accuracy" tag in meters (68% confidence), 0 = unset
+	Title    string     // "title" tag (NIP-24)
+	Summary  string     // "summary" tag (NIP-52)
+	Hashtags []string   // "t" tags (NIP-24)
+	Extra    nostr.Tags // Any other location tags (name, image, location, ...)
+}
+
+// Tags returns the location as a list of tags, g tag first
+func (l *Location) Tags() nostr.Tags {
+	tags := nostr.Tags{{"g", l.Geohash}}
+
+	if l.Title != "" {
+		tags = append(tags, nostr.Tag{"title", l.Title})
+	}
+	if l.Summary != "" {
+		tags = append(tags, nostr.Tag{"summary", l.Summary})
+	}
+	if l.Accuracy > 0 {
+		tags = append(tags, nostr.Tag{"accuracy", FormatAccuracy(l.Accuracy)})
+	}
+	tags = append(tags, l.Extra...)
+	for _, t := range l.Hashtags {
+		tags = append(tags, nostr.Tag{"t", t})
+	}
+
+	return tags
+}
+
+// Uncertainty returns the 68% confidence radius in meters combining the
+// geohash cell and the accuracy tag
+func (l *Location) Uncertainty() float64 {
+	return Uncertainty(l.Geohash, l.Accuracy)
+}
+
+// Get returns the value of the first extra tag with the given key
+func (l *Location) Get(key string) string {
+	if tag := l.Extra.Find(key); tag != nil {
+		return tag[1]
+	}
+	return ""
+}
+
+// FromTags collects location tags from a tag list. Event level tags (d, p,
+// expiration) are ignored. The g tag is required.
+func FromTags(tags nostr.Tags) (*Location, error) {
+	loc := &Location{}
+
+	for _, tag := range tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "g":
+			// Events may carry prefixes of the geohash, the longest is the location
+			if len(tag[1]) > len(loc.Geohash) {
+				loc.Geohash = tag[1]
+			}
+		case "accuracy":
+			accuracy, err := ParseAccuracy(tag[1])
+			if err != nil {
+				return nil, err
+			}
+			loc.Accuracy = accuracy
+		case "title":
+			loc.Title = tag[1]
+		case "summary":
+			loc.Summary = tag[1]
+		case "t":
+			loc.Hashtags = append(loc.Hashtags, tag[1])
+		case "d", "p", "expiration":
+			// Event tags, not part of the location
+		default:
+			loc.Extra = append(loc.Extra, tag)
+		}
+	}
+
+	if loc.Geohash == "" {
+		return nil, fmt.Errorf("missing required g tag")
+	}
+
+	return loc, nil
+}
+
+// ParseAccuracy parses an accuracy tag value, which must be a positive number
+func ParseAccuracy(value string) (float64, error) {
+	accuracy, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(accuracy) || math.IsInf(accuracy, 0) || accuracy <= 0 {
+		return 0, fmt.Errorf("invalid accuracy %q: must be a positive number", value)
+	}
+	return accuracy, nil
+}
+
+// FormatAccuracy formats an accuracy value for the accuracy tag
+func FormatAccuracy(accuracy float64) string {
+	return strconv.FormatFloat(accuracy, 'f', -1, 64)
+}
+
+// Encode encodes coordinates to a geohash, precision 0 uses the full 12
+// characters
+func Encode(lat, lon float64, precision int) string {
+	if precision > 0 {
+		return geohash.EncodeWithPrecision(lat, lon, uint(precision))
+	}
+	return geohash.Encode(lat, lon)
+}
+
+// geohashAlphabet is the base32 character set used by geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// ValidGeohash reports whether s is a 1-12 character geohash
+func ValidGeohash(s string) bool {
+	if len(s) == 0 || len(s) > 12 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune(geohashAlphabet, c) {
+			return false
+		}
+	}
+	return true
+}
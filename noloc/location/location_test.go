@@ -0,0 +1,92 @@
+package location
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestTagsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		loc  Location
+	}{
+		{"geohash only", Location{Geohash: "u4pruydqqvj"}},
+		{"all fields", Location{
+			Geohash:  "ud9wr3",
+			Accuracy: 12.5,
+			Title:    "Market square",
+			Summary:  "Meeting point",
+			Hashtags: []string{"helsinki", "market"},
+			Extra:    nostr.Tags{{"name", "Kauppatori"}, {"location", "Eteläranta"}},
+		}},
+		{"fractional accuracy", Location{Geohash: "u4pr", Accuracy: 0.25}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := tt.loc.Tags()
+			if tags[0][0] != "g" || tags[0][1] != tt.loc.Geohash {
+				t.Errorf("first tag = %v, want the g tag", tags[0])
+			}
+			got, err := FromTags(tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.loc) {
+				t.Errorf("FromTags(Tags()) = %+v, want %+v", *got, tt.loc)
+			}
+		})
+	}
+}
+
+func TestFromTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    nostr.Tags
+		want    Location
+		wantErr bool
+	}{
+		{
+			name: "event tags ignored",
+			tags: nostr.Tags{{"d", "x"}, {"p", "abc"}, {"expiration", "1700000000"}, {"g", "u4pr"}},
+			want: Location{Geohash: "u4pr"},
+		},
+		{
+			name: "short tags skipped",
+			tags: nostr.Tags{{"g"}, {"title"}, {"g", "u4pr"}},
+			want: Location{Geohash: "u4pr"},
+		},
+		{
+			name: "other tags are extra",
+			tags: nostr.Tags{{"g", "u4pr"}, {"speed", "80"}},
+			want: Location{Geohash: "u4pr", Extra: nostr.Tags{{"speed", "80"}}},
+		},
+		{name: "missing g", tags: nostr.Tags{{"title", "Nowhere"}}, wantErr: true},
+		{name: "accuracy not a number", tags: nostr.Tags{{"g", "u4pr"}, {"accuracy", "50m"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromTags(tt.tags)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	loc, _ := FromTags(nostr.Tags{{"g", "u4pr"}, {"speed", "80"}})
+	if loc.Get("speed") != "80" || loc.Get("name") != "" {
+		t.Errorf("Get = %q, %q", loc.Get("speed"), loc.Get("name"))
+	}
+}
@@ -0,0 +1,141 @@
+package location
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+)
+
+// Policy describes how a position is obfuscated before it is encoded. The
+// zero Policy leaves positions unchanged.
+type Policy struct {
+	Fuzz      float64 // Random offset within this radius in meters, 0 = none
+	Grid      float64 // Snap to the center of grid cells of this size in meters, 0 = none
+	Precision int     // Maximum geohash precision, 0 = no cap
+}
+
+// Offset is a random point in the unit disc. It is drawn once per position
+// and scaled by the fuzz radius, so that receivers with different policies
+// get offsets in the same direction and cannot average them out.
+type Offset struct {
+	X, Y float64 // East and north, X² + Y² ≤ 1
+}
+
+// RandomOffset draws an offset evenly distributed over the unit disc
+func RandomOffset() Offset {
+	r := math.Sqrt(rand.Float64())
+	theta := rand.Float64() * 2 * math.Pi
+	return Offset{X: r * math.Cos(theta), Y: r * math.Sin(theta)}
+}
+
+// Active reports whether the policy changes positions at all
+func (p Policy) Active() bool {
+	return p.Fuzz > 0 || p.Grid > 0 || p.Precision > 0
+}
+
+// Strictest combines two policies, taking the most private value of each
+// setting
+func (p Policy) Strictest(other Policy) Policy {
+	combined := Policy{
+		Fuzz:      math.Max(p.Fuzz, other.Fuzz),
+		Grid:      math.Max(p.Grid, other.Grid),
+		Precision: p.Precision,
+	}
+	if other.Precision > 0 && (combined.Precision == 0 || other.Precision < combined.Precision) {
+		combined.Precision = other.Precision
+	}
+	return combined
+}
+
+// String describes the policy for logs
+func (p Policy) String() string {
+	var parts []string
+	if p.Fuzz > 0 {
+		parts = append(parts, fmt.Sprintf("fuzz %gm", p.Fuzz))
+	}
+	if p.Grid > 0 {
+		parts = append(parts, fmt.Sprintf("grid %gm", p.Grid))
+	}
+	if p.Precision > 0 {
+		parts = append(parts, fmt.Sprintf("precision ≤ %d", p.Precision))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Obfuscate applies the policy with a fresh random offset
+func (p Policy) Obfuscate(lat, lon, accuracy float64, precision int) (string, float64) {
+	return p.Apply(lat, lon, accuracy, precision, RandomOffset())
+}
+
+// Apply moves the position by the offset scaled to the fuzz radius, snaps
+// it to the grid and encodes it with the requested precision, 0 = all 12
+// characters, capped by the policy. It returns the geohash and the accuracy
+// to publish, which grows by the RMS error of the added noise like
+// Uncertainty combines errors. When noise is added the precision is also
+// limited to the shortest one whose cell fits within the new accuracy.
+func (p Policy) Apply(lat, lon, accuracy float64, precision int, offset Offset) (string, float64) {
+	noisy := p.Fuzz > 0 || p.Grid > 0
+	if p.Fuzz > 0 {
+		lat, lon = movePoint(lat, lon, offset.X*p.Fuzz, offset.Y*p.Fuzz)
+	}
+	if p.Grid > 0 {
+		lat, lon = snapToGrid(lat, lon, p.Grid)
+	}
+	accuracy = p.accuracy(accuracy)
+
+	if precision <= 0 || precision > 12 {
+		precision = 12
+	}
+	if noisy {
+		precision = min(precision, PrecisionForAccuracy(lat, lon, accuracy))
+	}
+	if p.Precision > 0 {
+		precision = min(precision, p.Precision)
+	}
+
+	return Encode(lat, lon, precision), accuracy
+}
+
+// accuracy combines the accuracy of a position with the noise the policy
+// adds. An offset spread evenly over a disc of radius r has an RMS distance
+// of r/√2, a position spread evenly over a grid cell of size g has one of
+// g/√6 from the cell center.
+func (p Policy) accuracy(accuracy float64) float64 {
+	fuzz := p.Fuzz / math.Sqrt2
+	grid := p.Grid / math.Sqrt(6)
+	return math.Sqrt(accuracy*accuracy + fuzz*fuzz + grid*grid)
+}
+
+// movePoint moves a point by meters east and north, along the great circle
+// in that direction so that it ends up exactly that far away
+func movePoint(lat, lon, east, north float64) (float64, float64) {
+	delta := math.Hypot(east, north) / earthRadius
+	bearing := math.Atan2(east, north)
+	phi := lat * math.Pi / 180
+
+	sinPhi2 := math.Sin(phi)*math.Cos(delta) + math.Cos(phi)*math.Sin(delta)*math.Cos(bearing)
+	phi2 := math.Asin(math.Max(-1, math.Min(1, sinPhi2)))
+	dLon := math.Atan2(math.Sin(bearing)*math.Sin(delta)*math.Cos(phi), math.Cos(delta)-math.Sin(phi)*sinPhi2)
+	return phi2 * 180 / math.Pi, normalizeLon(lon + dLon*180/math.Pi)
+}
+
+// snapToGrid returns the center of the grid cell holding the point. Rows
+// are size meters high, and each row is split into cells size meters wide
+// at the row center.
+func snapToGrid(lat, lon, size float64) (float64, float64) {
+	dLat := size / earthRadius * 180 / math.Pi
+	lat = (math.Floor(lat/dLat) + 0.5) * dLat
+	lat = math.Max(-90, math.Min(90, lat))
+
+	cosLat := math.Cos(lat * math.Pi / 180)
+	if cosLat < 1e-9 {
+		return lat, 0
+	}
+	dLon := math.Min(360, dLat/cosLat)
+	lon = (math.Floor((lon+180)/dLon)+0.5)*dLon - 180
+	return lat, normalizeLon(lon)
+}
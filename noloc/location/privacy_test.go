@@ -0,0 +1,165 @@
+package location
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFuzzWithinRadius(t *testing.T) {
+	const fuzz = 200.0
+	for _, p := range []struct{ lat, lon float64 }{{60.1699, 24.9384}, {0, 179.9999}, {-89.99, 0}} {
+		var sum float64
+		for range 1000 {
+			offset := RandomOffset()
+			r := math.Hypot(offset.X, offset.Y)
+			if r > 1 {
+				t.Fatalf("offset %+v outside the unit disc", offset)
+			}
+			lat, lon := movePoint(p.lat, p.lon, offset.X*fuzz, offset.Y*fuzz)
+			d := Distance(p.lat, p.lon, lat, lon)
+			if math.Abs(d-r*fuzz) > 0.01 {
+				t.Fatalf("%v,%v moved %.2f m, want %.2f m", p.lat, p.lon, d, r*fuzz)
+			}
+			sum += d * d
+		}
+		// An offset spread evenly over the disc has an RMS distance of r/√2
+		if rms := math.Sqrt(sum / 1000); math.Abs(rms-fuzz/math.Sqrt2) > 15 {
+			t.Errorf("%v,%v: RMS offset %.1f m, want about %.1f m", p.lat, p.lon, rms, fuzz/math.Sqrt2)
+		}
+	}
+}
+
+func TestSnapToGrid(t *testing.T) {
+	tests := []struct {
+		lat, lon, size float64
+	}{
+		{60.1699, 24.9384, 500},
+		{-33.8688, 151.2093, 1000},
+		{0.0001, -0.0001, 100},
+		{51.5, 179.999, 2000},
+	}
+	for _, tt := range tests {
+		lat, lon := snapToGrid(tt.lat, tt.lon, tt.size)
+
+		// The center of the row, and of the cell within the row
+		dLat := tt.size / earthRadius * 180 / math.Pi
+		if row := (lat / dLat) - 0.5; math.Abs(row-math.Round(row)) > 1e-6 {
+			t.Errorf("%v,%v: latitude %v is not a row center", tt.lat, tt.lon, lat)
+		}
+		dLon := dLat / math.Cos(lat*math.Pi/180)
+		if col := (lon+180)/dLon - 0.5; math.Abs(col-math.Round(col)) > 1e-6 && lon+dLon/2 < 180 {
+			t.Errorf("%v,%v: longitude %v is not a cell center", tt.lat, tt.lon, lon)
+		}
+		if d := Distance(tt.lat, tt.lon, lat, lon); d > tt.size/math.Sqrt2*1.01 {
+			t.Errorf("%v,%v: center %.1f m away, farther than half a cell diagonal", tt.lat, tt.lon, d)
+		}
+
+		// The center stays put, and so does every point of its cell
+		if cLat, cLon := snapToGrid(lat, lon, tt.size); cLat != lat || cLon != lon {
+			t.Errorf("%v,%v: center %v,%v snaps to %v,%v", tt.lat, tt.lon, lat, lon, cLat, cLon)
+		}
+		if cLat, cLon := snapToGrid(lat+dLat/3, lon-dLon/3, tt.size); cLat != lat || math.Abs(cLon-lon) > 1e-9 {
+			t.Errorf("%v,%v: a point of the same cell snaps to %v,%v", tt.lat, tt.lon, cLat, cLon)
+		}
+	}
+}
+
+func TestPolicyApply(t *testing.T) {
+	const lat, lon = 60.1699, 24.9384
+	exact := Encode(lat, lon, 12)
+	tests := []struct {
+		name      string
+		policy    Policy
+		accuracy  float64
+		precision int
+		offset    Offset
+		geohash   string // Expected prefix of the exact geohash, "" when moved
+		length    int
+		wantAcc   float64
+	}{
+		{name: "no policy", accuracy: 10, geohash: exact, length: 12, wantAcc: 10},
+		{name: "requested precision", accuracy: 10, precision: 7, geohash: exact[:7], length: 7, wantAcc: 10},
+		{name: "precision cap", policy: Policy{Precision: 5}, accuracy: 10, precision: 9, geohash: exact[:5], length: 5, wantAcc: 10},
+		{name: "cap above requested", policy: Policy{Precision: 9}, accuracy: 10, precision: 6, geohash: exact[:6], length: 6, wantAcc: 10},
+		{name: "cap without requested", policy: Policy{Precision: 8}, geohash: exact[:8], length: 8},
+		{
+			name:   "fuzz without offset",
+			policy: Policy{Fuzz: 200}, accuracy: 30,
+			wantAcc: math.Sqrt(30*30 + 200*200/2),
+		},
+		{
+			name:   "fuzz and grid",
+			policy: Policy{Fuzz: 200, Grid: 600}, accuracy: 30, offset: Offset{X: 0.6, Y: -0.8},
+			wantAcc: math.Sqrt(30*30 + 200*200/2 + 600*600/6),
+		},
+		{
+			name:   "grid capped",
+			policy: Policy{Grid: 1000, Precision: 4},
+			length: 4, wantAcc: 1000 / math.Sqrt(6),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh, accuracy := tt.policy.Apply(lat, lon, tt.accuracy, tt.precision, tt.offset)
+			if math.Abs(accuracy-tt.wantAcc) > 1e-9 {
+				t.Errorf("accuracy = %v, want %v", accuracy, tt.wantAcc)
+			}
+			if tt.geohash != "" && gh != tt.geohash {
+				t.Errorf("geohash = %s, want %s", gh, tt.geohash)
+			}
+			if tt.length > 0 && len(gh) != tt.length {
+				t.Errorf("geohash = %s, want %d characters", gh, tt.length)
+			}
+			// Noise limits the precision to a cell that fits the accuracy
+			if tt.policy.Fuzz > 0 || tt.policy.Grid > 0 {
+				cLat, cLon := GeohashBox(gh).Center()
+				if len(gh) > PrecisionForAccuracy(cLat, cLon, accuracy) {
+					t.Errorf("geohash %s is more precise than the accuracy %.0f m", gh, accuracy)
+				}
+			}
+		})
+	}
+
+	// The fuzzed position stays within the radius plus the cell size
+	policy := Policy{Fuzz: 200}
+	for range 200 {
+		gh, _ := policy.Obfuscate(lat, lon, 0, 12)
+		cLat, cLon := GeohashBox(gh).Center()
+		if d := Distance(lat, lon, cLat, cLon); d > policy.Fuzz+GeohashError(gh) {
+			t.Fatalf("geohash %s is %.0f m away", gh, d)
+		}
+	}
+}
+
+func TestStrictest(t *testing.T) {
+	tests := []struct {
+		a, b, want Policy
+	}{
+		{Policy{}, Policy{}, Policy{}},
+		{Policy{Fuzz: 100}, Policy{Grid: 500}, Policy{Fuzz: 100, Grid: 500}},
+		{Policy{Fuzz: 100, Grid: 800}, Policy{Fuzz: 300, Grid: 500}, Policy{Fuzz: 300, Grid: 800}},
+		{Policy{Precision: 7}, Policy{Precision: 5}, Policy{Precision: 5}},
+		{Policy{Precision: 5}, Policy{Precision: 7}, Policy{Precision: 5}},
+		{Policy{Precision: 6}, Policy{}, Policy{Precision: 6}},
+		{Policy{}, Policy{Precision: 6}, Policy{Precision: 6}},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Strictest(tt.b); got != tt.want {
+			t.Errorf("%+v.Strictest(%+v) = %+v, want %+v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyString(t *testing.T) {
+	if s := (Policy{}).String(); s != "none" {
+		t.Errorf("empty policy = %q", s)
+	}
+	s := Policy{Fuzz: 200, Grid: 500, Precision: 6}.String()
+	for _, part := range []string{"fuzz 200m", "grid 500m", "precision ≤ 6"} {
+		if !strings.Contains(s, part) {
+			t.Errorf("%q misses %q", s, part)
+		}
+	}
+}
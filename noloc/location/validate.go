@@ -0,0 +1,168 @@
+package location
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Violation codes reported by Validate
+const (
+	CodeInvalidKind          = "invalid-kind"
+	CodeInvalidID            = "invalid-id"
+	CodeInvalidSignature     = "invalid-signature"
+	CodeMissingGeohash       = "missing-g"
+	CodeInvalidGeohash       = "invalid-geohash"
+	CodeInvalidGeohashPrefix = "invalid-g-prefix"
+	CodeContentNotEmpty      = "content-not-empty"
+	CodePublicLocationTag    = "public-location-tag"
+	CodeDecryptFailed        = "decrypt-failed"
+	CodeContentNotTagArray   = "content-not-tag-array"
+	CodeInvalidAccuracy      = "invalid-accuracy"
+	CodeInvalidExpiration    = "invalid-expiration"
+	CodeInvalidPTag          = "invalid-p-tag"
+)
+
+// Violation is a single spec violation found in an event
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Report holds the validation result of one event
+type Report struct {
+	EventID    string      `json:"id"`
+	Kind       int         `json:"kind"`
+	Decrypted  bool        `json:"decrypted"` // Content of kind 30473 was decrypted and checked
+	Violations []Violation `json:"violations"`
+}
+
+// Valid reports whether no violations were found
+func (r *Report) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+func (r *Report) add(code, format string, args ...interface{}) {
+	r.Violations = append(r.Violations, Violation{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks an event against doc/NostrLocation.md. Encrypted content
+// of kind 30473 is only checked when a receiver to decrypt it is given.
+func Validate(event *nostr.Event, receiver nostr.Cipher) *Report {
+	report := &Report{EventID: event.ID, Kind: event.Kind, Violations: []Violation{}}
+
+	if !event.CheckID() {
+		report.add(CodeInvalidID, "event id does not match serialized event")
+	}
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		report.add(CodeInvalidSignature, "signature verification failed")
+	}
+
+	validateEventTags(report, event.Tags)
+
+	switch event.Kind {
+	case KindPublic:
+		if event.Content != "" {
+			report.add(CodeContentNotEmpty, "kind %d must have empty content", KindPublic)
+		}
+		validateLocationTags(report, event.Tags)
+
+	case KindPrivate:
+		for _, key := range []string{"g", "accuracy"} {
+			if event.Tags.Find(key) != nil {
+				report.add(CodePublicLocationTag, "kind %d must not have public %s tag", KindPrivate, key)
+			}
+		}
+		if receiver == nil {
+			break
+		}
+
+		plaintext, err := receiver.Decrypt(context.Background(), event.Content, event.PubKey)
+		if err != nil {
+			report.add(CodeDecryptFailed, "failed to decrypt content: %v", err)
+			break
+		}
+		report.Decrypted = true
+
+		var tags nostr.Tags
+		if err := json.Unmarshal([]byte(plaintext), &tags); err != nil {
+			report.add(CodeContentNotTagArray, "decrypted content is not a JSON array of string arrays")
+			break
+		}
+		validateLocationTags(report, tags)
+
+	default:
+		report.add(CodeInvalidKind, "kind %d is not a location event kind (%d or %d)", event.Kind, KindPublic, KindPrivate)
+	}
+
+	return report
+}
+
+func validateEventTags(report *Report, tags nostr.Tags) {
+	for _, tag := range tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "expiration":
+			if _, err := strconv.ParseInt(tag[1], 10, 64); err != nil {
+				report.add(CodeInvalidExpiration, "expiration %q is not an integer timestamp", tag[1])
+			}
+		case "p":
+			if !nostr.IsValid32ByteHex(tag[1]) {
+				report.add(CodeInvalidPTag, "p tag %q is not a 32-byte lowercase hex pubkey", tag[1])
+			}
+		}
+	}
+}
+
+func validateLocationTags(report *Report, tags nostr.Tags) {
+	if tags.Find("g") == nil {
+		report.add(CodeMissingGeohash, "required g tag is missing")
+	}
+
+	for _, tag := range tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "g":
+			if !ValidGeohash(tag[1]) {
+				report.add(CodeInvalidGeohash, "g tag %q is not a valid geohash", tag[1])
+			}
+		case "accuracy":
+			if _, err := ParseAccuracy(tag[1]); err != nil {
+				report.add(CodeInvalidAccuracy, "accuracy %q is not a positive number", tag[1])
+			}
+		}
+	}
+	validateGeohashPrefixes(report, tags)
+}
+
+// validateGeohashPrefixes checks that extra g tags are proper prefixes of
+// the longest one, the location
+func validateGeohashPrefixes(report *Report, tags nostr.Tags) {
+	longest := -1
+	for i, tag := range tags {
+		if len(tag) >= 2 && tag[0] == "g" && (longest < 0 || len(tag[1]) > len(tags[longest][1])) {
+			longest = i
+		}
+	}
+	if longest < 0 {
+		return
+	}
+
+	geohash := tags[longest][1]
+	for i, tag := range tags {
+		if i == longest || len(tag) < 2 || tag[0] != "g" {
+			continue
+		}
+		if len(tag[1]) == len(geohash) || !strings.HasPrefix(geohash, tag[1]) {
+			report.add(CodeInvalidGeohashPrefix, "g tag %q is not a proper prefix of the geohash %q", tag[1], geohash)
+		}
+	}
+}
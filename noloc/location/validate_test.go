@@ -0,0 +1,161 @@
+package location
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+)
+
+func testSigner(t *testing.T) keyer.KeySigner {
+	t.Helper()
+	signer, err := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// signed signs an event after its tags or content were changed
+func signed(t *testing.T, signer keyer.KeySigner, event *nostr.Event) *nostr.Event {
+	t.Helper()
+	if err := signer.SignEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func codes(report *Report) []string {
+	var codes []string
+	for _, v := range report.Violations {
+		codes = append(codes, v.Code)
+	}
+	return codes
+}
+
+func TestValidatePublic(t *testing.T) {
+	sender := testSigner(t)
+	public := func(tags nostr.Tags, content string) *nostr.Event {
+		return signed(t, sender, &nostr.Event{Kind: KindPublic, CreatedAt: nostr.Now(), Tags: tags, Content: content})
+	}
+
+	tests := []struct {
+		name  string
+		event *nostr.Event
+		want  []string
+	}{
+		{"valid", public(nostr.Tags{{"d", "x"}, {"g", "u4pruydqqvj"}, {"accuracy", "12.5"}, {"expiration", "1700000000"}}, ""), nil},
+		{"missing g", public(nostr.Tags{{"d", "x"}, {"title", "Nowhere"}}, ""), []string{CodeMissingGeohash}},
+		{"uppercase geohash", public(nostr.Tags{{"g", "U4PRUYD"}}, ""), []string{CodeInvalidGeohash}},
+		{"geohash with a", public(nostr.Tags{{"g", "u4pa"}}, ""), []string{CodeInvalidGeohash}},
+		{"geohash too long", public(nostr.Tags{{"g", "u4pruydqqvjxy"}}, ""), []string{CodeInvalidGeohash}},
+		{"prefix g tags", public(nostr.Tags{{"g", "u4pruyd"}, {"g", "u"}, {"g", "u4pr"}}, ""), nil},
+		{"g tag not a prefix", public(nostr.Tags{{"g", "u4pruyd"}, {"g", "u4ps"}}, ""), []string{CodeInvalidGeohashPrefix}},
+		{"duplicate g tag", public(nostr.Tags{{"g", "u4pr"}, {"g", "u4pr"}}, ""), []string{CodeInvalidGeohashPrefix}},
+		{"content", public(nostr.Tags{{"g", "u4pr"}}, "hello"), []string{CodeContentNotEmpty}},
+		{"zero accuracy", public(nostr.Tags{{"g", "u4pr"}, {"accuracy", "0"}}, ""), []string{CodeInvalidAccuracy}},
+		{"negative accuracy", public(nostr.Tags{{"g", "u4pr"}, {"accuracy", "-5"}}, ""), []string{CodeInvalidAccuracy}},
+		{"accuracy not a number", public(nostr.Tags{{"g", "u4pr"}, {"accuracy", "50m"}}, ""), []string{CodeInvalidAccuracy}},
+		{"accuracy NaN", public(nostr.Tags{{"g", "u4pr"}, {"accuracy", "NaN"}}, ""), []string{CodeInvalidAccuracy}},
+		{"accuracy infinite", public(nostr.Tags{{"g", "u4pr"}, {"accuracy", "+Inf"}}, ""), []string{CodeInvalidAccuracy}},
+		{"expiration not a number", public(nostr.Tags{{"g", "u4pr"}, {"expiration", "soon"}}, ""), []string{CodeInvalidExpiration}},
+		{"expiration fraction", public(nostr.Tags{{"g", "u4pr"}, {"expiration", "1700000000.5"}}, ""), []string{CodeInvalidExpiration}},
+		{"uppercase p tag", public(nostr.Tags{{"g", "u4pr"}, {"p", "ABCDEF"}}, ""), []string{CodeInvalidPTag}},
+		{"several", public(nostr.Tags{{"accuracy", "0"}, {"expiration", "x"}}, "x"), []string{CodeInvalidExpiration, CodeContentNotEmpty, CodeMissingGeohash, CodeInvalidAccuracy}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Validate(tt.event, nil)
+			if got := codes(report); !slices.Equal(got, tt.want) {
+				t.Errorf("codes = %v, want %v", got, tt.want)
+			}
+			if report.Valid() != (len(tt.want) == 0) {
+				t.Errorf("Valid() = %v with violations %v", report.Valid(), report.Violations)
+			}
+		})
+	}
+}
+
+func TestValidateEnvelope(t *testing.T) {
+	sender := testSigner(t)
+
+	tampered := signed(t, sender, &nostr.Event{Kind: KindPublic, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"g", "u4pr"}}})
+	tampered.Tags = nostr.Tags{{"g", "u4ps"}}
+
+	badSig := signed(t, sender, &nostr.Event{Kind: KindPublic, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"g", "u4pr"}}})
+	badSig.Sig = strings.Repeat("0", 128)
+
+	wrongKind := signed(t, sender, &nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"g", "u4pr"}}})
+
+	tests := []struct {
+		name  string
+		event *nostr.Event
+		want  []string
+	}{
+		{"tampered", tampered, []string{CodeInvalidID, CodeInvalidSignature}},
+		{"bad signature", badSig, []string{CodeInvalidSignature}},
+		{"wrong kind", wrongKind, []string{CodeInvalidKind}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := codes(Validate(tt.event, nil)); !slices.Equal(got, tt.want) {
+				t.Errorf("codes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePrivate(t *testing.T) {
+	ctx := context.Background()
+	sender := testSigner(t)
+	receiver := testSigner(t)
+	receiverPubkey, _ := receiver.GetPublicKey(ctx)
+
+	valid, err := SignPrivateEvent(ctx, sender, receiverPubkey, &Location{Geohash: "u4pruyd", Accuracy: 20}, EventOptions{D: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := func(plaintext string, tags nostr.Tags) *nostr.Event {
+		content, err := sender.Encrypt(ctx, plaintext, receiverPubkey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed(t, sender, &nostr.Event{Kind: KindPrivate, CreatedAt: nostr.Now(), Tags: tags, Content: content})
+	}
+
+	tests := []struct {
+		name      string
+		event     *nostr.Event
+		receiver  nostr.Cipher
+		want      []string
+		decrypted bool
+	}{
+		{"valid", valid, receiver, nil, true},
+		{"not decrypted without receiver", valid, nil, nil, false},
+		{"wrong receiver", valid, testSigner(t), []string{CodeDecryptFailed}, false},
+		{"public g tag", encrypted(`[["g","u4pr"]]`, nostr.Tags{{"g", "u4pr"}}), receiver, []string{CodePublicLocationTag}, true},
+		{"public accuracy tag", encrypted(`[["g","u4pr"]]`, nostr.Tags{{"accuracy", "5"}}), receiver, []string{CodePublicLocationTag}, true},
+		{"content not json", encrypted(`u4pr`, nil), receiver, []string{CodeContentNotTagArray}, true},
+		{"content object", encrypted(`{"g":"u4pr"}`, nil), receiver, []string{CodeContentNotTagArray}, true},
+		{"content g not a prefix", encrypted(`[["g","u4pr"],["g","ud"]]`, nil), receiver, []string{CodeInvalidGeohashPrefix}, true},
+		{"content without g", encrypted(`[["title","x"]]`, nil), receiver, []string{CodeMissingGeohash}, true},
+		{"content bad accuracy", encrypted(`[["g","u4pr"],["accuracy","-1"]]`, nil), receiver, []string{CodeInvalidAccuracy}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Validate(tt.event, tt.receiver)
+			if got := codes(report); !slices.Equal(got, tt.want) {
+				t.Errorf("codes = %v, want %v", got, tt.want)
+			}
+			if report.Decrypted != tt.decrypted {
+				t.Errorf("Decrypted = %v, want %v", report.Decrypted, tt.decrypted)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package location
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+// WrapJitter is how far into the past the timestamp of a gift wrap is
+// randomly moved, so that relays cannot tell when a location was sent.
+// Receivers resuming a subscription look back this far for gift wraps.
+const WrapJitter = 12 * time.Hour
+
+// ErrNotLocation is returned when a gift wrap holds something other than a
+// location event, such as a NIP-17 direct message
+var ErrNotLocation = errors.New("gift wrap does not contain a location event")
+
+// WrapPrivateEvent creates a kind 30473 location event and hides it in a
+// NIP-59 gift wrap: the unsigned event is sealed by the signer and the seal
+// is encrypted and signed by a random one-time key. Relays only see the
+// one-time key, the receiver p-tag, a jittered timestamp and an expiration
+// counted from it, not the sender, the d-tag or when the location was sent.
+// opts.Anon is ignored, the gift wrap is addressed to the receiver anyway.
+func WrapPrivateEvent(ctx context.Context, signer nostr.Keyer, receiverPubkey string, loc *Location, opts EventOptions) (*nostr.Event, error) {
+	opts.Anon = false
+	rumor, err := privateEvent(ctx, signer, receiverPubkey, loc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := jitteredTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	// Relays may drop the wrap together with the location inside. The
+	// rumor's expiration minus the TTL is the send time, so the wrap expires
+	// after the jitter, the TTL and a random margin from its own timestamp,
+	// never before the location.
+	var expiration nostr.Timestamp
+	if opts.TTL > 0 {
+		margin, err := randomDuration(WrapJitter)
+		if err != nil {
+			return nil, err
+		}
+		expiration = createdAt + nostr.Timestamp((WrapJitter+opts.TTL+margin)/time.Second)
+	}
+
+	wrap, err := nip59.GiftWrap(*rumor, receiverPubkey,
+		func(plaintext string) (string, error) {
+			return signer.Encrypt(ctx, plaintext, receiverPubkey)
+		},
+		func(seal *nostr.Event) error {
+			return signer.SignEvent(ctx, seal)
+		},
+		func(wrap *nostr.Event) {
+			wrap.CreatedAt = createdAt
+			if expiration > 0 {
+				wrap.Tags = append(wrap.Tags, nostr.Tag{"expiration", strconv.FormatInt(int64(expiration), 10)})
+			}
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gift wrap event: %w", err)
+	}
+
+	return &wrap, nil
+}
+
+// UnwrapEvent opens a gift wrap addressed to the receiver and returns the
+// location event inside, with the seal author as its pubkey. Its content is
+// decrypted like that of any kind 30473 event.
+func UnwrapEvent(ctx context.Context, receiver nostr.Cipher, wrap *nostr.Event) (*nostr.Event, error) {
+	if wrap.Kind != nostr.KindGiftWrap {
+		return nil, fmt.Errorf("unexpected kind %d, expected %d", wrap.Kind, nostr.KindGiftWrap)
+	}
+
+	rumor, err := nip59.GiftUnwrap(*wrap, func(otherPubkey, ciphertext string) (string, error) {
+		return receiver.Decrypt(ctx, ciphertext, otherPubkey)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rumor.Kind != KindPrivate {
+		return nil, ErrNotLocation
+	}
+
+	return &rumor, nil
+}
+
+// jitteredTimestamp returns a random time within WrapJitter before now
+func jitteredTimestamp() (nostr.Timestamp, error) {
+	offset, err := randomDuration(WrapJitter)
+	if err != nil {
+		return 0, err
+	}
+	return nostr.Now() - nostr.Timestamp(offset/time.Second), nil
+}
+
+// randomDuration returns a random number of whole seconds below max
+func randomDuration(max time.Duration) (time.Duration, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max/time.Second)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate timestamp: %w", err)
+	}
+	return time.Duration(n.Int64()) * time.Second, nil
+}
@@ -0,0 +1,125 @@
+package location
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+func TestWrapPrivateEvent(t *testing.T) {
+	ctx := context.Background()
+	sender := testSigner(t)
+	receiver := testSigner(t)
+	senderPubkey, _ := sender.GetPublicKey(ctx)
+	receiverPubkey, _ := receiver.GetPublicKey(ctx)
+	loc := &Location{Geohash: "u4pruyd", Accuracy: 20}
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{"no expiration", 0},
+		{"ten minutes", 10 * time.Minute},
+		{"one day", 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := time.Now()
+			wrap, err := WrapPrivateEvent(ctx, sender, receiverPubkey, loc, EventOptions{D: "x", TTL: tt.ttl})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if wrap.Kind != nostr.KindGiftWrap || wrap.PubKey == senderPubkey {
+				t.Errorf("wrap kind %d by %s", wrap.Kind, wrap.PubKey)
+			}
+			if wrap.Tags.Find("d") != nil {
+				t.Error("wrap reveals the d-tag")
+			}
+			created := wrap.CreatedAt.Time()
+			if created.After(sent.Add(time.Second)) || created.Before(sent.Add(-WrapJitter-time.Second)) {
+				t.Errorf("created_at %s outside the jitter window", created)
+			}
+
+			rumor, err := UnwrapEvent(ctx, receiver, wrap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rumor.PubKey != senderPubkey || rumor.Kind != KindPrivate {
+				t.Errorf("rumor kind %d by %s", rumor.Kind, rumor.PubKey)
+			}
+
+			expiration := Expiration(wrap)
+			if tt.ttl == 0 {
+				if !expiration.IsZero() {
+					t.Errorf("wrap expires at %s without a TTL", expiration)
+				}
+				return
+			}
+
+			// The wrap outlives the location, and its expiration counts from
+			// the jittered created_at instead of the send time
+			if expiration.Before(Expiration(rumor)) {
+				t.Errorf("wrap expires at %s before the location at %s", expiration, Expiration(rumor))
+			}
+			lifetime := expiration.Sub(created)
+			if lifetime < WrapJitter+tt.ttl || lifetime >= 2*WrapJitter+tt.ttl {
+				t.Errorf("wrap lifetime %s, want jitter + TTL + margin", lifetime)
+			}
+			if tag := wrap.Tags.Find("expiration"); tag[1] == rumor.Tags.Find("expiration")[1] {
+				t.Error("wrap copies the expiration of the location")
+			}
+		})
+	}
+}
+
+func TestUnwrapEvent(t *testing.T) {
+	ctx := context.Background()
+	sender := testSigner(t)
+	receiver := testSigner(t)
+	receiverPubkey, _ := receiver.GetPublicKey(ctx)
+
+	wrap, err := WrapPrivateEvent(ctx, sender, receiverPubkey, &Location{Geohash: "u4pr"}, EventOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnwrapEvent(ctx, testSigner(t), wrap); err == nil {
+		t.Error("unwrapped with the wrong receiver")
+	}
+
+	public := *wrap
+	public.Kind = KindPublic
+	if _, err := UnwrapEvent(ctx, receiver, &public); err == nil {
+		t.Error("unwrapped an event that is not a gift wrap")
+	}
+
+	message := nostr.Event{Kind: nostr.KindDirectMessage, CreatedAt: nostr.Now(), Content: "hi", Tags: nostr.Tags{{"p", receiverPubkey}}}
+	dm, err := nip59.GiftWrap(message, receiverPubkey,
+		func(plaintext string) (string, error) { return sender.Encrypt(ctx, plaintext, receiverPubkey) },
+		func(seal *nostr.Event) error { return sender.SignEvent(ctx, seal) },
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnwrapEvent(ctx, receiver, &dm); !errors.Is(err, ErrNotLocation) {
+		t.Errorf("err = %v, want ErrNotLocation", err)
+	}
+}
+
+func TestRandomDuration(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d, err := randomDuration(time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d < 0 || d >= time.Minute || d%time.Second != 0 {
+			t.Fatalf("randomDuration = %s", d)
+		}
+	}
+}
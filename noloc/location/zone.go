@@ -0,0 +1,150 @@
+package location
+
+import (
+	"fmt"
+	"math"
+)
+
+// Actions for positions inside a private zone
+const (
+	ZoneSkip        = "skip"        // Publish nothing
+	ZoneCoarse      = "coarse"      // Publish the coarse geohash of the zone
+	ZonePlaceholder = "placeholder" // Publish the zone label at its coarse geohash
+)
+
+// Zone is a private area, such as a home or office, whose positions are
+// never published exactly. It is a circle around Lat/Lon, or a polygon when
+// Polygon has vertices.
+type Zone struct {
+	Name      string
+	Lat       float64
+	Lon       float64
+	Radius    float64      // Circle radius in meters
+	Polygon   [][2]float64 // Vertices as latitude, longitude
+	Action    string       // ZoneSkip, ZoneCoarse or ZonePlaceholder
+	Label     string       // Placeholder title, defaults to Name
+	Precision int          // Precision of the coarse geohash, 0 = derived from the zone size
+}
+
+// Validate checks that the zone has an area and a known action
+func (z *Zone) Validate() error {
+	switch z.Action {
+	case ZoneSkip, ZoneCoarse, ZonePlaceholder:
+	default:
+		return fmt.Errorf("zone %s: invalid action %q: must be skip, coarse or placeholder", z.Name, z.Action)
+	}
+	if len(z.Polygon) == 0 && z.Radius <= 0 {
+		return fmt.Errorf("zone %s: needs a radius or a polygon", z.Name)
+	}
+	if len(z.Polygon) > 0 && len(z.Polygon) < 3 {
+		return fmt.Errorf("zone %s: a polygon needs at least 3 vertices", z.Name)
+	}
+	if z.Precision < 0 || z.Precision > 12 {
+		return fmt.Errorf("zone %s: precision must be 0 to 12, 0 = derived from the zone size", z.Name)
+	}
+	return nil
+}
+
+// Contains reports whether the point lies inside the zone. Polygons are
+// tested in plain latitude/longitude and must not cross the antimeridian.
+func (z *Zone) Contains(lat, lon float64) bool {
+	if len(z.Polygon) == 0 {
+		return Distance(z.Lat, z.Lon, lat, lon) <= z.Radius
+	}
+
+	// Count crossings of a ray from the point towards increasing longitude
+	inside := false
+	for i, j := 0, len(z.Polygon)-1; i < len(z.Polygon); j, i = i, i+1 {
+		a, b := z.Polygon[i], z.Polygon[j]
+		if (a[0] > lat) != (b[0] > lat) {
+			crossLon := a[1] + (lat-a[0])/(b[0]-a[0])*(b[1]-a[1])
+			if lon < crossLon {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// center returns the center of the zone and the distance from it to the
+// farthest point of the zone
+func (z *Zone) center() (float64, float64, float64) {
+	if len(z.Polygon) == 0 {
+		return z.Lat, z.Lon, z.Radius
+	}
+
+	var lat, lon float64
+	for _, v := range z.Polygon {
+		lat += v[0]
+		lon += v[1]
+	}
+	lat /= float64(len(z.Polygon))
+	lon /= float64(len(z.Polygon))
+
+	var radius float64
+	for _, v := range z.Polygon {
+		radius = math.Max(radius, Distance(lat, lon, v[0], v[1]))
+	}
+	return lat, lon, radius
+}
+
+// Geohash returns the coarse geohash published for positions in the zone,
+// the cell at the zone center with the longest precision whose
+// half-diagonal still covers the zone, or with Precision when it is set.
+// Every position in the zone gets the same cell.
+func (z *Zone) Geohash() string {
+	lat, lon, radius := z.center()
+	if z.Precision > 0 {
+		return Encode(lat, lon, z.Precision)
+	}
+	for precision := 12; precision > 1; precision-- {
+		if gh := Encode(lat, lon, precision); GeohashError(gh) >= radius {
+			return gh
+		}
+	}
+	return Encode(lat, lon, 1)
+}
+
+// FindZone returns the first zone holding the point, nil when there is none
+func FindZone(zones []Zone, lat, lon float64) *Zone {
+	for i := range zones {
+		if zones[i].Contains(lat, lon) {
+			return &zones[i]
+		}
+	}
+	return nil
+}
+
+// Protect sets the geohash and accuracy of loc for a position. Inside a
+// private zone the zone's action decides what is published, elsewhere the
+// position is obfuscated by the policy (see Policy.Apply). It returns the
+// zone the position is in, nil when there is none, and false when nothing
+// may be published.
+func Protect(loc *Location, zones []Zone, policy Policy, lat, lon, accuracy float64, precision int, offset Offset) (*Zone, bool) {
+	zone := FindZone(zones, lat, lon)
+	if zone == nil {
+		loc.Geohash, loc.Accuracy = policy.Apply(lat, lon, accuracy, precision, offset)
+		return nil, true
+	}
+
+	switch zone.Action {
+	case ZoneCoarse, ZonePlaceholder:
+		loc.Geohash = zone.Geohash()
+		if policy.Precision > 0 && len(loc.Geohash) > policy.Precision {
+			loc.Geohash = loc.Geohash[:policy.Precision]
+		}
+		// The cell stands for the whole zone, no accuracy is claimed
+		loc.Accuracy = 0
+		if zone.Action == ZonePlaceholder {
+			loc.Title = zone.Name
+			if zone.Label != "" {
+				loc.Title = zone.Label
+			}
+			loc.Summary = ""
+			loc.Extra = nil // Tags such as location or name may name the place
+		}
+		return zone, true
+	default:
+		return zone, false
+	}
+}
@@ -0,0 +1,178 @@
+package location
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// office is a triangle in central Helsinki
+var office = Zone{
+	Name:    "office",
+	Polygon: [][2]float64{{60.170, 24.940}, {60.171, 24.945}, {60.168, 24.946}},
+	Action:  ZonePlaceholder,
+}
+
+func TestZoneContains(t *testing.T) {
+	home := Zone{Name: "home", Lat: 60.1699, Lon: 24.9384, Radius: 300, Action: ZoneSkip}
+	square := Zone{Name: "square", Polygon: [][2]float64{{0, 0}, {0, 1}, {1, 1}, {1, 0}}, Action: ZoneSkip}
+
+	tests := []struct {
+		name     string
+		zone     Zone
+		lat, lon float64
+		want     bool
+	}{
+		{"circle center", home, 60.1699, 24.9384, true},
+		{"inside circle", home, 60.1710, 24.9400, true},
+		{"outside circle", home, 60.1750, 24.9384, false},
+		{"circle edge", home, 60.1699 + 299.0/earthRadius*180/math.Pi, 24.9384, true},
+		{"inside polygon", office, 60.1697, 24.9437, true},
+		{"outside polygon", office, 60.1720, 24.9400, false},
+		{"east of polygon", office, 60.1697, 24.9500, false},
+		{"inside square", square, 0.5, 0.5, true},
+		{"outside square", square, 1.5, 0.5, false},
+		{"west edge", square, 0.5, 0, true},
+		{"south edge", square, 0, 0.5, true},
+		{"east edge", square, 0.5, 1, false},
+		{"north edge", square, 1, 0.5, false},
+		{"vertex", square, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.zone.Contains(tt.lat, tt.lon); got != tt.want {
+				t.Errorf("Contains(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+
+	// Points on an edge shared by two zones belong to exactly one of them
+	west := Zone{Polygon: [][2]float64{{0, 0}, {0, 1}, {1, 1}, {1, 0}}}
+	east := Zone{Polygon: [][2]float64{{0, 1}, {0, 2}, {1, 2}, {1, 1}}}
+	for _, lat := range []float64{0.1, 0.5, 0.9} {
+		if west.Contains(lat, 1) == east.Contains(lat, 1) {
+			t.Errorf("%v,1 is in both or neither of two adjacent zones", lat)
+		}
+	}
+}
+
+func TestZoneGeohash(t *testing.T) {
+	tests := []struct {
+		name string
+		zone Zone
+		want string
+	}{
+		{"small circle", Zone{Lat: 60.1699, Lon: 24.9384, Radius: 50}, "ud9wr3x"},
+		{"circle", Zone{Lat: 60.1699, Lon: 24.9384, Radius: 300}, "ud9wr3"},
+		{"large circle", Zone{Lat: 60.1699, Lon: 24.9384, Radius: 20000}, "ud9"},
+		{"precision", Zone{Lat: 60.1699, Lon: 24.9384, Radius: 300, Precision: 8}, "ud9wr3xe"},
+		{"polygon", office, "ud9wr9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh := tt.zone.Geohash()
+			if gh != tt.want {
+				t.Errorf("geohash = %s, want %s", gh, tt.want)
+			}
+			// The cell covers the whole zone unless the precision is forced
+			if lat, lon, radius := tt.zone.center(); tt.zone.Precision == 0 && GeohashError(gh) < radius {
+				t.Errorf("cell %s (%.0f m) does not cover %.0f m around %v,%v", gh, GeohashError(gh), radius, lat, lon)
+			}
+		})
+	}
+}
+
+func TestZoneValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		zone Zone
+		err  string
+	}{
+		{"circle", Zone{Name: "a", Radius: 100, Action: ZoneSkip}, ""},
+		{"polygon", office, ""},
+		{"zone default precision", Zone{Name: "a", Radius: 100, Action: ZoneCoarse}, ""},
+		{"max precision", Zone{Name: "a", Radius: 100, Action: ZoneCoarse, Precision: 12}, ""},
+		{"no action", Zone{Name: "a", Radius: 100}, "invalid action"},
+		{"unknown action", Zone{Name: "a", Radius: 100, Action: "hide"}, "invalid action"},
+		{"no area", Zone{Name: "a", Action: ZoneSkip}, "radius or a polygon"},
+		{"two vertices", Zone{Name: "a", Polygon: [][2]float64{{0, 0}, {1, 1}}, Action: ZoneSkip}, "at least 3"},
+		{"negative precision", Zone{Name: "a", Radius: 100, Action: ZoneCoarse, Precision: -1}, "precision must be 0 to 12"},
+		{"precision too long", Zone{Name: "a", Radius: 100, Action: ZoneCoarse, Precision: 13}, "precision must be 0 to 12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.zone.Validate()
+			if tt.err == "" && err != nil {
+				t.Errorf("err = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestProtect(t *testing.T) {
+	const lat, lon = 60.1699, 24.9384
+	zones := []Zone{
+		{Name: "home", Lat: lat, Lon: lon, Radius: 100, Action: ZoneSkip},
+		{Name: "gym", Lat: 60.1800, Lon: 24.9500, Radius: 200, Action: ZoneCoarse},
+		{Name: "office", Polygon: office.Polygon, Action: ZonePlaceholder, Label: "At work"},
+		{Name: "cafe", Lat: 60.1600, Lon: 24.9300, Radius: 100, Action: ZonePlaceholder},
+	}
+	details := func() *Location {
+		return &Location{
+			Title:    "Lunch",
+			Summary:  "With Bob",
+			Hashtags: []string{"food"},
+			Extra:    nostr.Tags{{"location", "Esplanadi 1"}, {"name", "lunch"}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		policy   Policy
+		zone     string
+		ok       bool
+		geohash  string
+		title    string
+		extra    bool // Extra and Summary are kept
+	}{
+		{name: "outside", lat: 60.2, lon: 24.9, ok: true, geohash: Encode(60.2, 24.9, 9), title: "Lunch", extra: true},
+		{name: "skip", lat: lat, lon: lon, zone: "home", ok: false},
+		{name: "coarse", lat: 60.1801, lon: 24.9501, zone: "gym", ok: true, geohash: zones[1].Geohash(), title: "Lunch", extra: true},
+		{name: "coarse capped", lat: 60.1801, lon: 24.9501, policy: Policy{Precision: 4}, zone: "gym", ok: true, geohash: zones[1].Geohash()[:4], title: "Lunch", extra: true},
+		{name: "placeholder label", lat: 60.1697, lon: 24.9437, zone: "office", ok: true, geohash: office.Geohash(), title: "At work"},
+		{name: "placeholder name", lat: 60.1601, lon: 24.9301, zone: "cafe", ok: true, geohash: zones[3].Geohash(), title: "cafe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := details()
+			zone, ok := Protect(loc, zones, tt.policy, tt.lat, tt.lon, 10, 9, Offset{})
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if (zone == nil && tt.zone != "") || (zone != nil && zone.Name != tt.zone) {
+				t.Fatalf("zone = %v, want %q", zone, tt.zone)
+			}
+			if !ok {
+				return
+			}
+			if loc.Geohash != tt.geohash || loc.Title != tt.title {
+				t.Errorf("geohash %s, title %q, want %s, %q", loc.Geohash, loc.Title, tt.geohash, tt.title)
+			}
+			if zone != nil && loc.Accuracy != 0 {
+				t.Errorf("accuracy = %v inside a zone, want none", loc.Accuracy)
+			}
+			if kept := loc.Summary != "" && len(loc.Extra) == 2; kept != tt.extra {
+				t.Errorf("summary %q, extra %v, want kept %v", loc.Summary, loc.Extra, tt.extra)
+			}
+			if len(loc.Hashtags) != 1 {
+				t.Errorf("hashtags = %v", loc.Hashtags)
+			}
+		})
+	}
+}